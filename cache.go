@@ -0,0 +1,379 @@
+package fetch
+
+import (
+	"time"
+
+	. "github.com/tinywasm/fmt"
+)
+
+// Cache is a private HTTP cache for GET responses. Fresh responses are
+// served without a round trip; stale ones are revalidated with
+// If-None-Match / If-Modified-Since and a 304 turns back into the cached
+// response. It honours Cache-Control (max-age, no-cache, no-store),
+// Expires, Age, ETag, Last-Modified and Vary.
+//
+// Successful unsafe requests (POST, PUT, DELETE, ...) invalidate the
+// cached GET response of their URL. Stream requests bypass the cache.
+type Cache struct {
+	store        CacheStore
+	staleIfError bool
+}
+
+// CacheStore persists cache entries. Keys combine the method and the
+// resolved URL; Vary is checked against the stored entry.
+//
+// Its methods never run on the JS event loop, so WASM implementations may
+// block on promises. They must be safe for concurrent use.
+type CacheStore interface {
+	Load(key string) (*CacheEntry, bool)
+	Store(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+// CacheEntry is a stored response.
+type CacheEntry struct {
+	URL     string
+	Status  int
+	Headers []Header
+	Body    []byte
+	// Vary holds the request headers named by the Vary response header,
+	// as sent with the request that produced the response.
+	Vary []Header
+	// RequestTime and ResponseTime are the Unix times in milliseconds when
+	// the request was sent and the response received, used to compute its age.
+	RequestTime  int64
+	ResponseTime int64
+}
+
+// NewCache creates a cache backed by store. A nil store means an
+// in-memory LRU holding up to 256 responses.
+func NewCache(store CacheStore) *Cache {
+	if store == nil {
+		store = NewMemoryCacheStore(256)
+	}
+	return &Cache{store: store}
+}
+
+// SetStaleIfError makes the cache serve stored responses, even stale
+// ones, when the server cannot be reached: network errors, timeouts and
+// likely CORS blocks (how browsers report being offline). Responses marked
+// must-revalidate are never served stale.
+func (c *Cache) SetStaleIfError(enabled bool) *Cache {
+	c.staleIfError = enabled
+	return c
+}
+
+// SetCache sets the HTTP cache of the default client.
+func SetCache(cache *Cache) {
+	defaultClient.SetCache(cache)
+}
+
+// SetCache sets the HTTP cache of the client. It runs inside the
+// middleware, around retries. nil disables caching.
+func (c *Client) SetCache(cache *Cache) *Client {
+	c.cache = cache
+	return c
+}
+
+// heuristicStatuses may be cached without explicit freshness (RFC 9110 15.1).
+var heuristicStatuses = []int{200, 203, 204, 300, 301, 308, 404, 405, 410, 414, 501}
+
+// handler wraps next with the cache.
+func (c *Cache) handler(next Handler) Handler {
+	return func(r *Request, callback func(*Response, error)) {
+		if r.stream || hasDirective(headerValues(r.GetHeaders(), "Cache-Control"), "no-store") {
+			next(r, callback)
+			return
+		}
+		// Stores may block, so they are only used off the JS event loop.
+		if r.method != "GET" {
+			// A successful unsafe request invalidates the cached response.
+			next(r, func(resp *Response, err error) {
+				if err != nil || resp.Status >= 400 || isSafe(r.method) {
+					callback(resp, err)
+					return
+				}
+				go func() {
+					c.store.Delete(cacheKey(r.url))
+					callback(resp, err)
+				}()
+			})
+			return
+		}
+		go c.get(r, next, callback)
+	}
+}
+
+// get serves a GET request from the cache, revalidating or fetching it.
+func (c *Cache) get(r *Request, next Handler, callback func(*Response, error)) {
+	key := cacheKey(r.url)
+	entry, ok := c.store.Load(key)
+	if ok && !entry.matches(r) {
+		ok = false
+	}
+	noCache := hasDirective(headerValues(r.GetHeaders(), "Cache-Control"), "no-cache")
+	if ok && !noCache && entry.fresh(now()) {
+		callback(entry.response(r), nil)
+		return
+	}
+
+	req := r
+	if ok {
+		conditional := *r
+		conditional.headers = r.headers[:len(r.headers):len(r.headers)]
+		if etag := entry.header("ETag"); etag != "" {
+			conditional.Header("If-None-Match", etag)
+		}
+		if modified := entry.header("Last-Modified"); modified != "" {
+			conditional.Header("If-Modified-Since", modified)
+		}
+		req = &conditional
+	}
+
+	requestTime := now()
+	next(req, func(resp *Response, err error) {
+		if err != nil {
+			if ok && c.servesStale(entry, err) {
+				callback(entry.response(r), nil)
+				return
+			}
+			callback(resp, err)
+			return
+		}
+		go func() {
+			if ok && resp.Status == 304 {
+				entry = entry.revalidated(resp, requestTime, now())
+				c.store.Store(key, entry)
+				callback(entry.response(r), nil)
+				return
+			}
+			if storable(r, resp) {
+				c.store.Store(key, newCacheEntry(r, resp, requestTime, now()))
+			} else if ok {
+				c.store.Delete(key)
+			}
+			callback(resp, nil)
+		}()
+	})
+}
+
+// servesStale reports whether entry replaces a request that failed with err.
+func (c *Cache) servesStale(entry *CacheEntry, err error) bool {
+	if !c.staleIfError || hasDirective(entry.header("Cache-Control"), "must-revalidate") {
+		return false
+	}
+	e, ok := err.(*Error)
+	return ok && (e.Kind == KindNetwork || e.Kind == KindTimeout || e.Kind == KindCORSLikely)
+}
+
+// cacheKey returns the store key of the GET response for url.
+func cacheKey(url string) string {
+	return "GET " + url
+}
+
+// storable reports whether resp to the GET request r may be stored
+// (RFC 9111 3).
+func storable(r *Request, resp *Response) bool {
+	cc := headerValues(resp.Headers, "Cache-Control")
+	if hasDirective(cc, "no-store") || resp.GetHeader("Vary") == "*" || !containsStatus(heuristicStatuses, resp.Status) {
+		return false
+	}
+	if hasHeader(r.GetHeaders(), "Authorization") &&
+		!hasDirective(cc, "public") && !hasDirective(cc, "must-revalidate") && !hasDirective(cc, "s-maxage") {
+		return false
+	}
+	_, maxAge := directive(cc, "max-age")
+	return maxAge || resp.GetHeader("Expires") != "" ||
+		resp.GetHeader("ETag") != "" || resp.GetHeader("Last-Modified") != ""
+}
+
+// newCacheEntry copies resp into an entry.
+func newCacheEntry(r *Request, resp *Response, requestTime, responseTime int64) *CacheEntry {
+	e := &CacheEntry{
+		URL:          r.url,
+		Status:       resp.Status,
+		Headers:      append([]Header(nil), resp.Headers...),
+		Body:         append([]byte(nil), resp.body...),
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+	for _, name := range splitList(headerValues(resp.Headers, "Vary")) {
+		e.Vary = append(e.Vary, Header{Key: name, Value: headerValues(r.GetHeaders(), name)})
+	}
+	return e
+}
+
+// matches reports whether the request headers named by Vary are unchanged.
+func (e *CacheEntry) matches(r *Request) bool {
+	for _, v := range e.Vary {
+		if headerValues(r.GetHeaders(), v.Key) != v.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// header returns the value of a stored response header.
+func (e *CacheEntry) header(key string) string {
+	return headerValues(e.Headers, key)
+}
+
+// revalidated returns a copy of the entry refreshed by a 304 response,
+// whose headers replace the stored ones (RFC 9111 4.3.4). Entries are
+// never modified in place, as other requests may be reading them.
+func (e *CacheEntry) revalidated(resp *Response, requestTime, responseTime int64) *CacheEntry {
+	var headers []Header
+	for _, h := range e.Headers {
+		if equalFold(h.Key, "Content-Length") || !hasHeader(resp.Headers, h.Key) {
+			headers = append(headers, h)
+		}
+	}
+	for _, h := range resp.Headers {
+		if !equalFold(h.Key, "Content-Length") {
+			headers = append(headers, h)
+		}
+	}
+	updated := *e
+	updated.Headers = headers
+	updated.RequestTime = requestTime
+	updated.ResponseTime = responseTime
+	return &updated
+}
+
+// response returns a copy of the stored response for r.
+func (e *CacheEntry) response(r *Request) *Response {
+	return &Response{
+		Status:     e.Status,
+		Headers:    append([]Header(nil), e.Headers...),
+		RequestURL: r.url,
+		Method:     r.method,
+		body:       append([]byte(nil), e.Body...),
+	}
+}
+
+// fresh reports whether the entry can be served without revalidation at
+// time now (RFC 9111 4.2).
+func (e *CacheEntry) fresh(now int64) bool {
+	cc := e.header("Cache-Control")
+	if hasDirective(cc, "no-cache") {
+		return false
+	}
+	return e.lifetime(cc) > e.age(now)
+}
+
+// lifetime returns the freshness lifetime in milliseconds.
+func (e *CacheEntry) lifetime(cc string) int64 {
+	if v, ok := directive(cc, "max-age"); ok {
+		secs, err := Convert(v).Int64()
+		if err != nil {
+			return 0
+		}
+		return secs * 1000
+	}
+	date := e.date()
+	if expires := e.header("Expires"); expires != "" {
+		t, err := time.Parse(time.RFC1123, expires)
+		if err != nil {
+			return 0
+		}
+		return t.UnixMilli() - date
+	}
+	// Heuristic freshness: 10% of the time since the last modification.
+	if modified := e.header("Last-Modified"); modified != "" {
+		if t, err := time.Parse(time.RFC1123, modified); err == nil && t.UnixMilli() < date {
+			return (date - t.UnixMilli()) / 10
+		}
+	}
+	return 0
+}
+
+// age returns the current age of the entry in milliseconds.
+func (e *CacheEntry) age(now int64) int64 {
+	apparent := max(0, e.ResponseTime-e.date())
+	corrected := e.ResponseTime - e.RequestTime
+	if secs, err := Convert(e.header("Age")).Int64(); err == nil && e.header("Age") != "" {
+		corrected += secs * 1000
+	}
+	return max(apparent, corrected) + now - e.ResponseTime
+}
+
+// date returns the Date header in Unix milliseconds, or the response time.
+func (e *CacheEntry) date() int64 {
+	if t, err := time.Parse(time.RFC1123, e.header("Date")); err == nil {
+		return t.UnixMilli()
+	}
+	return e.ResponseTime
+}
+
+// now returns the current Unix time in milliseconds.
+func now() int64 {
+	return time.Now().UnixMilli()
+}
+
+// headerValues returns the comma-joined values of key (case-insensitive).
+func headerValues(headers []Header, key string) string {
+	var value string
+	for _, h := range headers {
+		if equalFold(h.Key, key) {
+			if value != "" {
+				value += ", "
+			}
+			value += h.Value
+		}
+	}
+	return value
+}
+
+// splitList splits a comma-separated header value into trimmed elements.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range Convert(value).Split(",") {
+		if item = Convert(item).TrimSpace().String(); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// directive returns the value of a Cache-Control directive, unquoted.
+func directive(cc, name string) (string, bool) {
+	for _, d := range splitList(cc) {
+		key, value := d, ""
+		if i := Index(d, "="); i >= 0 {
+			key, value = Convert(d[:i]).TrimSpace().String(), Convert(d[i+1:]).TrimSpace().String()
+		}
+		if equalFold(key, name) {
+			if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+				value = value[1 : len(value)-1]
+			}
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// hasDirective reports whether cc contains the directive name.
+func hasDirective(cc, name string) bool {
+	_, ok := directive(cc, name)
+	return ok
+}
+
+// isSafe reports whether method is read-only, so it never invalidates
+// cached responses.
+func isSafe(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
+
+// containsStatus reports whether statuses contains status.
+func containsStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
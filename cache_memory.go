@@ -0,0 +1,77 @@
+package fetch
+
+import (
+	"container/list"
+	"sync"
+)
+
+// MemoryCacheStore is an in-memory CacheStore evicting the least recently
+// used entries beyond its capacity.
+type MemoryCacheStore struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List // front is the most recently used
+	entries map[string]*list.Element
+}
+
+// memoryItem is an element of MemoryCacheStore.order.
+type memoryItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewMemoryCacheStore creates a store holding up to maxEntries responses.
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		max:     maxEntries,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Load returns the entry stored under key, marking it as recently used.
+func (s *MemoryCacheStore) Load(key string) (*CacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(el)
+	return el.Value.(*memoryItem).entry, true
+}
+
+// Store stores entry under key, evicting the least recently used entries
+// beyond the capacity.
+func (s *MemoryCacheStore) Store(key string, entry *CacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		el.Value.(*memoryItem).entry = entry
+		s.order.MoveToFront(el)
+		return
+	}
+	s.entries[key] = s.order.PushFront(&memoryItem{key: key, entry: entry})
+	for s.max > 0 && s.order.Len() > s.max {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryItem).key)
+	}
+}
+
+// Delete removes the entry stored under key.
+func (s *MemoryCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.order.Remove(el)
+		delete(s.entries, key)
+	}
+}
+
+// Len returns the number of stored entries.
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
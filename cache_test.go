@@ -0,0 +1,155 @@
+package fetch_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/fetch"
+)
+
+// httpDate formats t as an HTTP date.
+func httpDate(t time.Time) string {
+	return t.UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT")
+}
+
+func TestCacheFreshness(t *testing.T) {
+	// stub returns a client whose transport answers with headers and counts calls.
+	stub := func(headers ...fetch.Header) (*fetch.Client, *int) {
+		calls := 0
+		transport := fetch.Handler(func(r *fetch.Request, callback func(*fetch.Response, error)) {
+			calls++
+			resp := &fetch.Response{Status: 200, Headers: headers, RequestURL: r.GetURL(), Method: r.GetMethod()}
+			callback(resp.SetBody([]byte(strconv.Itoa(calls))), nil)
+		})
+		return fetch.NewClient().SetTransport(transport).SetCache(fetch.NewCache(nil)), &calls
+	}
+	// callsAfterTwoGets sends two GETs and returns the number of transport calls.
+	callsAfterTwoGets := func(t *testing.T, headers ...fetch.Header) int {
+		t.Helper()
+		api, calls := stub(headers...)
+		for i := 0; i < 2; i++ {
+			if _, err := api.Get("http://stub.invalid/data").Do(); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+		return *calls
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		headers []fetch.Header
+		calls   int
+	}{
+		{"FutureExpires", []fetch.Header{{Key: "Expires", Value: httpDate(now.Add(time.Hour))}}, 1},
+		{"PastExpires", []fetch.Header{{Key: "Expires", Value: httpDate(now.Add(-time.Hour))}}, 2},
+		{"InvalidExpires", []fetch.Header{{Key: "Expires", Value: "0"}}, 2},
+		{"MaxAgeOverExpires", []fetch.Header{
+			{Key: "Cache-Control", Value: "max-age=60"},
+			{Key: "Expires", Value: httpDate(now.Add(-time.Hour))},
+		}, 1},
+		{"AgeBeyondMaxAge", []fetch.Header{
+			{Key: "Cache-Control", Value: "max-age=60"},
+			{Key: "Age", Value: "120"},
+		}, 2},
+		{"HeuristicLastModified", []fetch.Header{
+			{Key: "Date", Value: httpDate(now)},
+			{Key: "Last-Modified", Value: httpDate(now.Add(-240 * time.Hour))},
+		}, 1},
+		{"NoFreshnessInfo", nil, 2},
+		{"PrivateMaxAge", []fetch.Header{{Key: "Cache-Control", Value: `private, max-age="60"`}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if calls := callsAfterTwoGets(t, tt.headers...); calls != tt.calls {
+				t.Errorf("Expected %d transport calls, got %d", tt.calls, calls)
+			}
+		})
+	}
+
+	t.Run("Authorization", func(t *testing.T) {
+		api, calls := stub(fetch.Header{Key: "Cache-Control", Value: "max-age=60"})
+		for i := 0; i < 2; i++ {
+			api.Get("http://stub.invalid/data").Header("Authorization", "Bearer x").Do()
+		}
+		if *calls != 2 {
+			t.Errorf("Expected authorized responses not to be shared without public, got %d calls", *calls)
+		}
+	})
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+	// offline answers once with a stale response, then fails like a lost connection.
+	offline := func(cacheControl string) fetch.Transport {
+		calls := 0
+		return fetch.Handler(func(r *fetch.Request, callback func(*fetch.Response, error)) {
+			calls++
+			if calls > 1 {
+				callback(nil, &fetch.Error{Kind: fetch.KindNetwork, Method: r.GetMethod(), URL: r.GetURL()})
+				return
+			}
+			resp := &fetch.Response{
+				Status:     200,
+				Headers:    []fetch.Header{{Key: "Cache-Control", Value: cacheControl}, {Key: "ETag", Value: `"v1"`}},
+				RequestURL: r.GetURL(),
+				Method:     r.GetMethod(),
+			}
+			callback(resp.SetBody([]byte("stored")), nil)
+		})
+	}
+
+	t.Run("Served", func(t *testing.T) {
+		api := fetch.NewClient().
+			SetTransport(offline("no-cache")).
+			SetCache(fetch.NewCache(nil).SetStaleIfError(true))
+		api.Get("http://stub.invalid/data").Do()
+		resp, err := api.Get("http://stub.invalid/data").Do()
+		if err != nil || resp.Text() != "stored" {
+			t.Errorf("Expected the stored response while offline, got %v", err)
+		}
+	})
+
+	t.Run("MustRevalidate", func(t *testing.T) {
+		api := fetch.NewClient().
+			SetTransport(offline("no-cache, must-revalidate")).
+			SetCache(fetch.NewCache(nil).SetStaleIfError(true))
+		api.Get("http://stub.invalid/data").Do()
+		if _, err := api.Get("http://stub.invalid/data").Do(); !errors.Is(err, fetch.ErrNetwork) {
+			t.Errorf("Expected ErrNetwork for a must-revalidate response, got %v", err)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		api := fetch.NewClient().
+			SetTransport(offline("no-cache")).
+			SetCache(fetch.NewCache(nil))
+		api.Get("http://stub.invalid/data").Do()
+		if _, err := api.Get("http://stub.invalid/data").Do(); !errors.Is(err, fetch.ErrNetwork) {
+			t.Errorf("Expected ErrNetwork without SetStaleIfError, got %v", err)
+		}
+	})
+}
+
+func TestMemoryCacheStore(t *testing.T) {
+	store := fetch.NewMemoryCacheStore(2)
+	store.Store("a", &fetch.CacheEntry{Status: 200})
+	store.Store("b", &fetch.CacheEntry{Status: 200})
+	store.Load("a") // a is now the most recently used
+	store.Store("c", &fetch.CacheEntry{Status: 200})
+
+	if _, ok := store.Load("b"); ok {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	if _, ok := store.Load("a"); !ok {
+		t.Error("Expected the recently used entry to be kept")
+	}
+	if store.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", store.Len())
+	}
+	store.Delete("a")
+	if _, ok := store.Load("a"); ok || store.Len() != 1 {
+		t.Error("Expected the deleted entry to be gone")
+	}
+}
@@ -0,0 +1,306 @@
+//go:build wasm
+
+package fetch
+
+import (
+	"encoding/binary"
+	"sync"
+	"syscall/js"
+
+	. "github.com/tinywasm/fmt"
+)
+
+// BrowserCacheStore is a CacheStore persisting entries in the browser
+// Cache Storage API, so cached responses survive reloads and can be served
+// while offline (see Cache.SetStaleIfError). Keys are the same as for the
+// in-memory store. Once the stored entries exceed the quota, the least
+// recently used ones are evicted. Uses are tracked in memory, so after a
+// reload entries rank by the time they were stored until used again.
+//
+// Cache Storage is only available in secure contexts (https or localhost);
+// elsewhere the entries are kept in memory.
+type BrowserCacheStore struct {
+	name     string
+	maxBytes int64
+
+	mu       sync.Mutex
+	opened   bool
+	cache    js.Value
+	fallback *MemoryCacheStore      // used when Cache Storage is unavailable
+	index    map[string]*storedItem // stored entries by key
+	size     int64                  // total size of the stored entries
+}
+
+// storedItem tracks a persisted entry for the quota.
+type storedItem struct {
+	size int64
+	used int64 // last use, Unix milliseconds; the store time after a reload
+}
+
+// browserCacheURL prefixes the synthetic request URLs entries are stored under.
+const browserCacheURL = "https://fetch.cache.invalid/"
+
+// NewBrowserCacheStore creates a store persisting entries in the Cache
+// Storage cache called name, holding up to maxBytes of encoded entries.
+// A maxBytes of 0 means 50 MB.
+func NewBrowserCacheStore(name string, maxBytes int64) *BrowserCacheStore {
+	if maxBytes <= 0 {
+		maxBytes = 50 << 20
+	}
+	return &BrowserCacheStore{name: name, maxBytes: maxBytes}
+}
+
+// Load returns the entry stored under key.
+func (s *BrowserCacheStore) Load(key string) (*CacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.open() {
+		return s.fallback.Load(key)
+	}
+	item, ok := s.index[key]
+	if !ok {
+		return nil, false
+	}
+	resp, ok := await(s.cache.Call("match", browserCacheURL+encodeURIComponent(key)))
+	if !ok || resp.IsUndefined() {
+		s.forget(key)
+		return nil, false
+	}
+	buffer, ok := await(resp.Call("arrayBuffer"))
+	if !ok {
+		s.remove(key)
+		return nil, false
+	}
+	data := make([]byte, buffer.Get("byteLength").Int())
+	js.CopyBytesToGo(data, js.Global().Get("Uint8Array").New(buffer))
+	entry, ok := decodeCacheEntry(data)
+	if !ok {
+		s.remove(key)
+		return nil, false
+	}
+	item.used = now()
+	return entry, true
+}
+
+// Store persists entry under key, evicting the least recently used entries
+// to stay within the quota. Entries larger than the quota are not stored.
+func (s *BrowserCacheStore) Store(key string, entry *CacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.open() {
+		s.fallback.Store(key, entry)
+		return
+	}
+	data := encodeCacheEntry(entry)
+	size := int64(len(data))
+	s.remove(key)
+	if size > s.maxBytes {
+		return
+	}
+	s.evict(s.maxBytes - size)
+
+	used := now()
+	options := js.Global().Get("Object").New()
+	headers := js.Global().Get("Object").New()
+	headers.Set("Content-Type", "application/octet-stream")
+	headers.Set("X-Used", Convert(used).String())
+	headers.Set("X-Size", Convert(size).String())
+	options.Set("headers", headers)
+	put := func() bool {
+		resp := js.Global().Get("Response").New(jsBytes(data), options)
+		_, ok := await(s.cache.Call("put", browserCacheURL+encodeURIComponent(key), resp))
+		return ok
+	}
+	if !put() {
+		// The browser quota is exhausted: make room and try once more.
+		s.evict(s.size / 2)
+		if !put() {
+			return
+		}
+	}
+	s.index[key] = &storedItem{size: size, used: used}
+	s.size += size
+}
+
+// Delete removes the entry stored under key.
+func (s *BrowserCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.open() {
+		s.fallback.Delete(key)
+		return
+	}
+	s.remove(key)
+}
+
+// open opens the cache and indexes the stored entries on first use, reading
+// only their headers. It reports false when Cache Storage is unavailable.
+func (s *BrowserCacheStore) open() bool {
+	if s.opened {
+		return s.fallback == nil
+	}
+	s.opened = true
+	s.index = make(map[string]*storedItem)
+
+	caches := js.Global().Get("caches")
+	if caches.IsUndefined() {
+		s.fallback = NewMemoryCacheStore(256)
+		return false
+	}
+	cache, ok := await(caches.Call("open", s.name))
+	if !ok {
+		s.fallback = NewMemoryCacheStore(256)
+		return false
+	}
+	s.cache = cache
+
+	requests, ok := await(cache.Call("keys"))
+	if !ok {
+		return true
+	}
+	for i := 0; i < requests.Length(); i++ {
+		url := requests.Index(i).Get("url").String()
+		if !HasPrefix(url, browserCacheURL) {
+			continue
+		}
+		resp, ok := await(cache.Call("match", requests.Index(i)))
+		if !ok || resp.IsUndefined() {
+			continue
+		}
+		headers := resp.Get("headers")
+		used, _ := Convert(headers.Call("get", "X-Used").String()).Int64()
+		size, err := Convert(headers.Call("get", "X-Size").String()).Int64()
+		if err != nil || size <= 0 {
+			// Unknown size: drop the entry rather than read its body.
+			await(cache.Call("delete", requests.Index(i)))
+			continue
+		}
+		key := decodeURIComponent(url[len(browserCacheURL):])
+		s.index[key] = &storedItem{size: size, used: used}
+		s.size += size
+	}
+	return true
+}
+
+// evict removes the least recently used entries until at most limit bytes
+// are stored.
+func (s *BrowserCacheStore) evict(limit int64) {
+	for s.size > limit && len(s.index) > 0 {
+		var oldest string
+		var oldestUsed int64
+		for key, item := range s.index {
+			if oldest == "" || item.used < oldestUsed {
+				oldest, oldestUsed = key, item.used
+			}
+		}
+		s.remove(oldest)
+	}
+}
+
+// remove deletes the entry stored under key from the cache and the index.
+func (s *BrowserCacheStore) remove(key string) {
+	if _, ok := s.index[key]; !ok {
+		return
+	}
+	await(s.cache.Call("delete", browserCacheURL+encodeURIComponent(key)))
+	s.forget(key)
+}
+
+// forget drops key from the index.
+func (s *BrowserCacheStore) forget(key string) {
+	if item, ok := s.index[key]; ok {
+		s.size -= item.size
+		delete(s.index, key)
+	}
+}
+
+func encodeURIComponent(s string) string {
+	return js.Global().Call("encodeURIComponent", s).String()
+}
+
+func decodeURIComponent(s string) string {
+	return js.Global().Call("decodeURIComponent", s).String()
+}
+
+// cacheEntryVersion is the first byte of an encoded CacheEntry.
+const cacheEntryVersion = 1
+
+// encodeCacheEntry encodes e as length-prefixed fields.
+func encodeCacheEntry(e *CacheEntry) []byte {
+	data := []byte{cacheEntryVersion}
+	data = appendField(data, []byte(e.URL))
+	data = binary.AppendUvarint(data, uint64(e.Status))
+	data = binary.AppendVarint(data, e.RequestTime)
+	data = binary.AppendVarint(data, e.ResponseTime)
+	for _, headers := range [][]Header{e.Headers, e.Vary} {
+		data = binary.AppendUvarint(data, uint64(len(headers)))
+		for _, h := range headers {
+			data = appendField(data, []byte(h.Key))
+			data = appendField(data, []byte(h.Value))
+		}
+	}
+	return appendField(data, e.Body)
+}
+
+// decodeCacheEntry decodes an entry encoded by encodeCacheEntry.
+func decodeCacheEntry(data []byte) (*CacheEntry, bool) {
+	if len(data) == 0 || data[0] != cacheEntryVersion {
+		return nil, false
+	}
+	d := entryDecoder{data: data[1:]}
+	e := &CacheEntry{URL: string(d.field())}
+	e.Status = int(d.uvarint())
+	e.RequestTime = d.varint()
+	e.ResponseTime = d.varint()
+	for _, headers := range []*[]Header{&e.Headers, &e.Vary} {
+		n := d.uvarint()
+		for i := uint64(0); i < n && !d.failed; i++ {
+			*headers = append(*headers, Header{Key: string(d.field()), Value: string(d.field())})
+		}
+	}
+	e.Body = d.field()
+	return e, !d.failed
+}
+
+// appendField appends b prefixed with its length.
+func appendField(data, b []byte) []byte {
+	return append(binary.AppendUvarint(data, uint64(len(b))), b...)
+}
+
+// entryDecoder reads the fields of an encoded entry, recording whether the
+// data was truncated.
+type entryDecoder struct {
+	data   []byte
+	failed bool
+}
+
+func (d *entryDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.failed = true
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *entryDecoder) varint() int64 {
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.failed = true
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *entryDecoder) field() []byte {
+	n := d.uvarint()
+	if d.failed || n > uint64(len(d.data)) {
+		d.failed = true
+		return nil
+	}
+	b := d.data[:n:n]
+	d.data = d.data[n:]
+	return b
+}
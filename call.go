@@ -0,0 +1,14 @@
+package fetch
+
+import "context"
+
+// Call is a handle to an in-flight request returned by Send and Dispatch.
+type Call struct {
+	cancel context.CancelFunc
+}
+
+// Abort cancels the request. Unless the request has already completed,
+// its callback receives ErrAborted. Calling Abort more than once is a no-op.
+func (c *Call) Abort() {
+	c.cancel()
+}
@@ -0,0 +1,126 @@
+package fetch
+
+// Client holds the configuration shared by the requests it creates:
+// base URL, default headers, timeout, Dispatch handler and logger.
+// Clients are independent of each other, so one binary can talk to
+// several backends without sharing state.
+type Client struct {
+	baseURL string
+	headers []Header
+	timeout int
+	handler func(*Response)
+	logger  func(...any)
+
+	maxBodySize     int64
+	failOnHTTPError bool
+	retry           RetryPolicy
+	middleware      []Middleware
+	transport       Transport
+	cache           *Cache
+	flights         *flightGroup // nil unless SetCoalesce
+	limiter         *limiter     // nil unless SetMaxInFlight or SetMaxPerHost
+	rateLimits      []*rateLimiter
+}
+
+// defaultClient backs the package-level functions (Get, SetBaseURL, ...).
+var defaultClient = NewClient()
+
+// NewClient creates a new Client without base URL, headers or timeout.
+func NewClient() *Client {
+	return &Client{}
+}
+
+// SetBaseURL sets the base URL used to resolve relative endpoints.
+func (c *Client) SetBaseURL(url string) *Client {
+	c.baseURL = url
+	return c
+}
+
+// GetBaseURL returns the client base URL.
+func (c *Client) GetBaseURL() string {
+	return c.baseURL
+}
+
+// SetHeader sets a default header sent with every request of the client.
+// It replaces any previous default header with the same key (case-insensitive).
+// Headers set on the request itself take precedence.
+func (c *Client) SetHeader(key, value string) *Client {
+	for i, h := range c.headers {
+		if equalFold(h.Key, key) {
+			c.headers[i].Value = value
+			return c
+		}
+	}
+	c.headers = append(c.headers, Header{Key: key, Value: value})
+	return c
+}
+
+// SetTimeout sets the default request timeout in milliseconds.
+// A per-request Timeout overrides it.
+func (c *Client) SetTimeout(ms int) *Client {
+	c.timeout = ms
+	return c
+}
+
+// SetFailOnHTTPError makes every request of the client report non-2xx
+// responses as *HTTPError. See Request.FailOnHTTPError.
+func (c *Client) SetFailOnHTTPError(enabled bool) *Client {
+	c.failOnHTTPError = enabled
+	return c
+}
+
+// SetHandler sets the handler for Dispatch requests of the client.
+func (c *Client) SetHandler(fn func(*Response)) *Client {
+	c.handler = fn
+	return c
+}
+
+// SetLog sets the logger function for debugging.
+func (c *Client) SetLog(fn func(...any)) *Client {
+	c.logger = fn
+	return c
+}
+
+// Get creates a new GET request bound to the client.
+func (c *Client) Get(endpoint any) *Request {
+	return c.newRequest("GET", endpoint)
+}
+
+// Post creates a new POST request bound to the client.
+func (c *Client) Post(endpoint any) *Request {
+	return c.newRequest("POST", endpoint)
+}
+
+// Put creates a new PUT request bound to the client.
+func (c *Client) Put(endpoint any) *Request {
+	return c.newRequest("PUT", endpoint)
+}
+
+// Delete creates a new DELETE request bound to the client.
+func (c *Client) Delete(endpoint any) *Request {
+	return c.newRequest("DELETE", endpoint)
+}
+
+// Patch creates a new PATCH request bound to the client.
+func (c *Client) Patch(endpoint any) *Request {
+	return c.newRequest("PATCH", endpoint)
+}
+
+// Head creates a new HEAD request bound to the client.
+func (c *Client) Head(endpoint any) *Request {
+	return c.newRequest("HEAD", endpoint)
+}
+
+// Options creates a new OPTIONS request bound to the client.
+func (c *Client) Options(endpoint any) *Request {
+	return c.newRequest("OPTIONS", endpoint)
+}
+
+// Method creates a new request with an arbitrary HTTP method bound to the client.
+func (c *Client) Method(verb string, endpoint any) *Request {
+	return c.newRequest(verb, endpoint)
+}
+
+func (c *Client) newRequest(method string, endpoint any) *Request {
+	return &Request{client: c, method: method, endpoint: endpoint}
+}
@@ -5,65 +5,111 @@ package fetch
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"math/rand/v2"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/textproto"
+	"strings"
 	"time"
-
-	. "github.com/tinywasm/fmt"
 )
 
-// doRequest is the standard library implementation for making an HTTP request.
-func doRequest(r *Request, callback func(*Response, error)) {
+// DefaultTransport is used by clients without a transport of their own.
+// On the standard library it sends requests with http.DefaultClient.
+var DefaultTransport Transport = &HTTPTransport{}
+
+// HTTPTransport is the standard library Transport. It sends requests with
+// a net/http client, so TLS, proxies, connection pools and cookie jars are
+// configured on that client.
+type HTTPTransport struct {
+	// Client sends the requests. nil means http.DefaultClient.
+	Client *http.Client
+}
+
+// NewHTTPTransport returns a transport sending requests with client.
+func NewHTTPTransport(client *http.Client) *HTTPTransport {
+	return &HTTPTransport{Client: client}
+}
+
+// NewRoundTripperTransport returns a transport sending requests through rt,
+// e.g. a configured *http.Transport or a test double.
+func NewRoundTripperTransport(rt http.RoundTripper) *HTTPTransport {
+	return &HTTPTransport{Client: &http.Client{Transport: rt}}
+}
+
+// RoundTrip is the standard library implementation for making an HTTP request.
+func (t *HTTPTransport) RoundTrip(r *Request, callback func(*Response, error)) {
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
 	go func() {
-		// 1. Build the full URL.
-		fullURL, err := buildURL(r)
-		if err != nil {
-			callback(nil, err)
-			return
-		}
+		// 1. The full URL was resolved by Send.
+		fullURL := r.url
 
 		// 2. Prepare body reader.
+		// The size is -1 when unknown, sending the body chunked.
 		var bodyReader io.Reader
-		if len(r.body) > 0 {
-			bodyReader = bytes.NewReader(r.body)
+		var contentType string
+		size := int64(-1)
+		if r.multipart != nil {
+			bodyReader, contentType = streamMultipart(r.multipart)
+		} else if r.bodyReader != nil {
+			bodyReader, size = r.bodyReader, r.bodySize
+		} else if len(r.body) > 0 {
+			bodyReader, size = bytes.NewReader(r.body), int64(len(r.body))
+		}
+		if bodyReader != nil {
+			bodyReader = r.uploadBody(bodyReader, size)
 		}
 
 		// 3. Set up the request context with timeout.
-		ctx := context.Background()
-		if r.timeout > 0 {
-			var cancel context.CancelFunc
-			ctx, cancel = context.WithTimeout(ctx, time.Duration(r.timeout)*time.Millisecond)
-			defer cancel()
+		// It derives from Request.Context and is cancelled by Call.Abort.
+		// A streamed body releases the timeout once it is closed.
+		ctx := r.ctx
+		cancelTimeout := func() {}
+		if timeout := r.GetTimeout(); timeout > 0 {
+			ctx, cancelTimeout = context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
 		}
+		streaming := false
+		defer func() {
+			if !streaming {
+				cancelTimeout()
+			}
+		}()
 
 		// 4. Create the HTTP request.
 		req, err := http.NewRequestWithContext(ctx, r.method, fullURL, bodyReader)
 		if err != nil {
-			callback(nil, Errf("failed to create request: %s", err.Error()))
+			callback(nil, newError(KindBuild, r, fullURL, err))
 			return
 		}
+		if bodyReader != nil {
+			req.ContentLength = size
+			if size == 0 {
+				req.Body = http.NoBody
+			}
+		}
 
 		// 5. Add headers to the request.
-		for _, h := range r.headers {
+		for _, h := range r.GetHeaders() {
 			req.Header.Add(h.Key, h.Value)
 		}
-
-		// 6. Execute the request.
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			callback(nil, Errf("request failed: %s", err.Error()))
-			return
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
 		}
-		defer resp.Body.Close()
 
-		// 7. Read the response body.
-		responseBody, err := io.ReadAll(resp.Body)
+		// 6. Execute the request.
+		resp, err := client.Do(req)
 		if err != nil {
-			callback(nil, Errf("failed to read response body: %s", err.Error()))
+			callback(nil, newError(errorKind(r, err, KindNetwork), r, fullURL, err))
 			return
 		}
 
-		// 8. Construct the Response object.
+		// 7. Construct the Response object.
 		var headers []Header
 		for k, v := range resp.Header {
 			for _, val := range v {
@@ -76,13 +122,119 @@ func doRequest(r *Request, callback func(*Response, error)) {
 			Headers:    headers,
 			RequestURL: fullURL,
 			Method:     r.method,
-			body:       responseBody,
+		}
+
+		// 8. Refuse bodies larger than the limit before downloading them.
+		if err := r.checkBodySize(fullURL, resp.ContentLength); err != nil && !bodyless(r.method, resp.StatusCode) {
+			resp.Body.Close()
+			callback(nil, err)
+			return
+		}
+
+		// 9. Hand a streamed body over to the caller as it arrives.
+		if r.stream && !bodyless(r.method, resp.StatusCode) {
+			streaming = true
+			body := r.downloadBody(resp.Body, resp.ContentLength)
+			response.stream = r.limitBody(&streamBody{ReadCloser: body, r: r, url: fullURL, release: cancelTimeout}, fullURL)
+			callback(response, nil)
+			return
+		}
+		defer resp.Body.Close()
+
+		// 10. Read the response body, unless the response cannot have one.
+		if !bodyless(r.method, resp.StatusCode) {
+			response.body, err = io.ReadAll(r.limitBody(r.downloadBody(resp.Body, resp.ContentLength), fullURL))
+			if err != nil {
+				if _, ok := err.(*Error); !ok {
+					err = newError(errorKind(r, err, KindBodyRead), r, fullURL, err)
+				}
+				callback(nil, err)
+				return
+			}
 		}
 
 		callback(response, nil)
 	}()
 }
 
+// streamBody is a streamed response body reporting read errors as *Error.
+// Closing it releases the timeout of the request.
+type streamBody struct {
+	io.ReadCloser
+	r       *Request
+	url     string
+	release func()
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = newError(errorKind(b.r, err, KindBodyRead), b.r, b.url, err)
+	}
+	return n, err
+}
+
+func (b *streamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// streamMultipart returns a reader producing m as multipart/form-data while
+// it is consumed, along with the Content-Type carrying the boundary.
+func streamMultipart(m *Multipart) (io.Reader, string) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		for _, p := range m.parts {
+			h := make(textproto.MIMEHeader)
+			disposition := `form-data; name="` + quoteEscaper.Replace(p.Name) + `"`
+			if p.Filename != "" {
+				disposition += `; filename="` + quoteEscaper.Replace(p.Filename) + `"`
+				h.Set("Content-Type", p.ContentType)
+			}
+			h.Set("Content-Disposition", disposition)
+			w, err := mw.CreatePart(h)
+			if err == nil {
+				_, err = w.Write(p.Data)
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+	return pr, mw.FormDataContentType()
+}
+
+// quoteEscaper escapes quoted Content-Disposition parameters like mime/multipart.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// errorKind classifies a transport error, returning fallback when it was
+// neither an abort nor a timeout.
+func errorKind(r *Request, err error, fallback ErrorKind) ErrorKind {
+	if r.ctx.Err() == context.Canceled {
+		return KindAborted
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return KindTimeout
+	}
+	return fallback
+}
+
+// afterFunc calls fn after ms milliseconds. stop cancels the call.
+func afterFunc(ms int, fn func()) (stop func()) {
+	t := time.AfterFunc(time.Duration(ms)*time.Millisecond, fn)
+	return func() { t.Stop() }
+}
+
+// random returns a pseudo-random number in [0, 1).
+func random() float64 {
+	return rand.Float64()
+}
+
 func getOrigin() string {
 	return ""
 }
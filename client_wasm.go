@@ -3,32 +3,68 @@
 package fetch
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"syscall/js"
 
 	. "github.com/tinywasm/fmt"
 )
 
-// doRequest is the WASM implementation for making an HTTP request using the browser's fetch API.
-func doRequest(r *Request, callback func(*Response, error)) {
-	// 1. Build the full URL.
-	fullURL, err := buildURL(r)
-	if err != nil {
-		callback(nil, err)
+// DefaultTransport is used by clients without a transport of their own.
+// In WASM it is the browser fetch API.
+var DefaultTransport Transport = FetchTransport{}
+
+// FetchTransport is the WASM Transport backed by the browser's fetch API.
+type FetchTransport struct{}
+
+// RoundTrip is the WASM implementation for making an HTTP request using the browser's fetch API.
+func (t FetchTransport) RoundTrip(r *Request, callback func(*Response, error)) {
+	if r.bodyReader != nil && !(HasPrefix(r.url, "https:") && supportsRequestStreams()) {
+		// Without request streams the body is buffered first. The reader
+		// may block, so it is consumed off the JS event loop.
+		go func() {
+			data, err := io.ReadAll(r.bodyReader)
+			if err != nil {
+				callback(nil, newError(KindNetwork, r, r.url, err))
+				return
+			}
+			buffered := *r
+			buffered.body = data
+			buffered.bodyReader = nil
+			t.RoundTrip(&buffered, callback)
+		}()
+		return
+	}
+
+	// fetch cannot report upload progress, except by counting the chunks
+	// of a request stream.
+	if r.uploadProgress != nil && r.bodyReader == nil && (r.multipart != nil || len(r.body) > 0) {
+		xhrRoundTrip(r, callback)
 		return
 	}
 
+	// 1. The full URL was resolved by Send.
+	fullURL := r.url
+
 	// 2. Prepare request body.
 	var jsBody js.Value
-	if len(r.body) > 0 {
-		// Convert Go byte slice to a JS Uint8Array's buffer.
-		uint8Array := js.Global().Get("Uint8Array").New(len(r.body))
-		js.CopyBytesToJS(uint8Array, r.body)
-		jsBody = uint8Array.Get("buffer")
+	releaseBody := func() {}
+	if r.multipart != nil {
+		jsBody = jsFormData(r.multipart)
+	} else if r.bodyReader != nil {
+		jsBody, releaseBody = jsReadableStream(r.uploadBody(r.bodyReader, r.bodySize))
+	} else if len(r.body) > 0 {
+		jsBody = jsBytes(r.body).Get("buffer")
 	}
 
 	// 3. Prepare headers object for the fetch call.
+	// The browser sets the multipart Content-Type with its boundary.
 	jsHeaders := js.Global().Get("Headers").New()
-	for _, h := range r.headers {
+	for _, h := range r.GetHeaders() {
+		if r.multipart != nil && equalFold(h.Key, "Content-Type") {
+			continue
+		}
 		jsHeaders.Call("append", h.Key, h.Value)
 	}
 
@@ -39,142 +75,563 @@ func doRequest(r *Request, callback func(*Response, error)) {
 	if !jsBody.IsUndefined() {
 		options.Set("body", jsBody)
 	}
+	if r.bodyReader != nil {
+		// Required by the fetch spec for ReadableStream bodies.
+		options.Set("duplex", "half")
+	}
 
-	// 5. Handle timeout with AbortController.
-	if r.timeout > 0 {
-		controller := js.Global().Get("AbortController").New()
-		options.Set("signal", controller.Get("signal"))
-		js.Global().Call("setTimeout", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+	// 5. Handle timeout, context cancellation and Call.Abort with AbortController.
+	controller := js.Global().Get("AbortController").New()
+	options.Set("signal", controller.Get("signal"))
+
+	var timedOut bool
+	var timer js.Func
+	var timerID js.Value
+	if timeout := r.GetTimeout(); timeout > 0 {
+		timer = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			timedOut = true
 			controller.Call("abort")
 			return nil
-		}), r.timeout)
+		})
+		timerID = js.Global().Call("setTimeout", timer, timeout)
 	}
+	stopAbort := context.AfterFunc(r.ctx, func() {
+		controller.Call("abort")
+	})
 
 	// 6. Define promise handlers to bridge async JS to sync Go.
-	var success, failure, responseHandler js.Func
+	var failure, responseHandler, successBody js.Func
 
-	// cleanup releases the JS functions when the request is complete.
-	cleanup := func() {
-		success.Release()
+	// release stops the abort triggers once the body has been read.
+	release := func() {
+		stopAbort()
+		releaseBody()
+		if timer.Truthy() {
+			js.Global().Call("clearTimeout", timerID)
+			timer.Release()
+		}
+	}
+
+	// releaseHandlers releases the promise handlers once the chain settled.
+	releaseHandlers := func() {
 		failure.Release()
 		responseHandler.Release()
+		successBody.Release()
 	}
 
-	// success handles the final response body (as an ArrayBuffer).
-	success = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		// Convert the JS ArrayBuffer back to a Go []byte.
-		uint8Array := js.Global().Get("Uint8Array").New(args[0])
-		goBytes := make([]byte, uint8Array.Get("length").Int())
-		js.CopyBytesToGo(goBytes, uint8Array)
-
-		// The response object was partially built in responseHandler, we need to pass it here?
-		// No, the promise chain is tricky with how we want to return both headers/status AND body.
-		// So we will change how we handle this.
-		// Ideally, we want to capture the response object in the first promise,
-		// and then combine it with the body in the second promise.
+	// partialResponse holds status and headers until the body is read.
+	var partialResponse *Response
 
-		// However, the callback structure expects *Response.
-		// We'll use a closure variable to hold the partial response.
-		return nil
-	})
+	// fail classifies a rejected fetch or body read.
+	fail := func(jsErr js.Value) error {
+		kind := KindNetwork
+		switch {
+		case partialResponse != nil:
+			kind = KindBodyRead
+		case isTypeError(jsErr) && urlOrigin(fullURL) != getOrigin():
+			// fetch rejects with an opaque TypeError when CORS blocks a
+			// cross-origin request; network failures look the same.
+			kind = KindCORSLikely
+		}
+		return newError(errorKind(r, timedOut, kind), r, fullURL, Err(jsErrorMessage(jsErr)))
+	}
 
-	// Re-implementing logic to capture response details properly.
+	// tooLarge is set when the Content-Length exceeds MaxBodySize.
+	var tooLarge error
 
 	// failure handles any error in the promise chain.
 	failure = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		var errMsg string
-		if len(args) > 0 && !args[0].IsUndefined() && !args[0].IsNull() {
-			jsErr := args[0]
-			// Try to get error message
-			if jsErr.Type() == js.TypeString {
-				errMsg = jsErr.String()
-			} else if jsErr.Get("message").Type() == js.TypeString {
-				errMsg = jsErr.Get("message").String()
-			} else {
-				errMsg = jsErr.Call("toString").String()
-			}
+		var jsErr js.Value
+		if len(args) > 0 {
+			jsErr = args[0]
 		}
-		if errMsg == "" {
-			errMsg = "unknown network error (possibly CORS, network unavailable, or invalid URL)"
+		if tooLarge != nil {
+			callback(nil, tooLarge)
+		} else {
+			callback(nil, fail(jsErr))
 		}
-		err := Errf("fetch failed: %s (URL: %s)", errMsg, fullURL)
-
-		callback(nil, err)
-		cleanup()
+		release()
+		releaseHandlers()
 		return nil
 	})
 
-	var partialResponse *Response
-
 	// responseHandler handles the initial Response object from fetch.
 	responseHandler = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		jsResp := args[0]
 
-		status := jsResp.Get("status").Int()
+		partialResponse = &Response{
+			Status:     jsResp.Get("status").Int(),
+			Headers:    jsResponseHeaders(jsResp),
+			RequestURL: fullURL,
+			Method:     r.method,
+		}
 
-		// Extract headers
-		var headers []Header
-		// Headers iterator
-		jsHeaders := jsResp.Get("headers")
-		iterator := jsHeaders.Call("entries")
+		// HEAD, 204 and 304 responses have no body to read.
+		if bodyless(r.method, partialResponse.Status) || jsResp.Get("body").IsNull() {
+			return js.Null()
+		}
 
-		for {
-			entry := iterator.Call("next")
-			if entry.Get("done").Bool() {
-				break
-			}
-			pair := entry.Get("value")
-			headers = append(headers, Header{
-				Key:   pair.Index(0).String(),
-				Value: pair.Index(1).String(),
-			})
+		// Refuse bodies larger than the limit before downloading them.
+		size := contentLength(partialResponse.GetHeader("Content-Length"))
+		if tooLarge = r.checkBodySize(fullURL, size); tooLarge != nil {
+			controller.Call("abort")
+			return js.Global().Get("Promise").Call("reject")
 		}
 
-		partialResponse = &Response{
-			Status:     status,
-			Headers:    headers,
-			RequestURL: fullURL,
-			Method:     r.method,
+		// A streamed body is read by the caller through the stream reader,
+		// and download progress and the size limit are checked while
+		// reading it.
+		if r.stream || r.downloadProgress != nil || r.GetMaxBodySize() > 0 {
+			partialResponse.stream = r.limitBody(r.downloadBody(&jsStreamReader{
+				reader:  jsResp.Get("body").Call("getReader"),
+				fail:    fail,
+				release: release,
+			}, size), fullURL)
+			return js.Null()
 		}
 
-		// Always read the body as ArrayBuffer, regardless of status.
+		// Read the body as ArrayBuffer, regardless of status.
 		// The user is responsible for checking status code.
 		return jsResp.Call("arrayBuffer")
 	})
 
 	// successBody handles the ArrayBuffer from the response body.
-	successBody := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		uint8Array := js.Global().Get("Uint8Array").New(args[0])
-		goBytes := make([]byte, uint8Array.Get("length").Int())
-		js.CopyBytesToGo(goBytes, uint8Array)
+	successBody = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		releaseHandlers()
+		if partialResponse.stream != nil {
+			// Reading the stream blocks on promises, so it happens off the
+			// JS event loop, and so does the callback.
+			go func() {
+				if !r.stream {
+					body := partialResponse.stream
+					data, err := io.ReadAll(body)
+					body.Close()
+					if err != nil {
+						callback(nil, err)
+						return
+					}
+					partialResponse.stream = nil
+					partialResponse.body = data
+				}
+				callback(partialResponse, nil)
+			}()
+			return nil
+		}
 
-		if partialResponse != nil {
+		if args[0].Truthy() {
+			uint8Array := js.Global().Get("Uint8Array").New(args[0])
+			goBytes := make([]byte, uint8Array.Get("length").Int())
+			js.CopyBytesToGo(goBytes, uint8Array)
 			partialResponse.body = goBytes
-			callback(partialResponse, nil)
-		} else {
-			// Should not happen
-			callback(nil, Errf("internal error: response missing"))
 		}
 
-		cleanup()
+		callback(partialResponse, nil)
+		release()
 		return nil
 	})
 
-	// Re-assign success to successBody for clarity in cleanup if I used the previous name
-	// But I defined successBody separately. So I need to update cleanup.
-
-	cleanup = func() {
-		failure.Release()
-		responseHandler.Release()
-		successBody.Release()
-	}
-
 	js.Global().Call("fetch", fullURL, options).
 		Call("then", responseHandler).
 		Call("then", successBody).
 		Call("catch", failure)
 }
 
+// jsBytes copies data into a new JS Uint8Array.
+func jsBytes(data []byte) js.Value {
+	uint8Array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(uint8Array, data)
+	return uint8Array
+}
+
+// xhrRoundTrip sends the request with XMLHttpRequest, which unlike fetch
+// reports upload progress.
+func xhrRoundTrip(r *Request, callback func(*Response, error)) {
+	fullURL := r.url
+	xhr := js.Global().Get("XMLHttpRequest").New()
+	xhr.Call("open", r.method, fullURL)
+	xhr.Set("responseType", "arraybuffer")
+	if timeout := r.GetTimeout(); timeout > 0 {
+		xhr.Set("timeout", timeout)
+	}
+
+	// The browser sets the multipart Content-Type with its boundary.
+	for _, h := range r.GetHeaders() {
+		if r.multipart != nil && equalFold(h.Key, "Content-Type") {
+			continue
+		}
+		xhr.Call("setRequestHeader", h.Key, h.Value)
+	}
+
+	var jsBody js.Value
+	if r.multipart != nil {
+		jsBody = jsFormData(r.multipart)
+	} else {
+		jsBody = jsBytes(r.body)
+	}
+
+	stopAbort := context.AfterFunc(r.ctx, func() {
+		xhr.Call("abort")
+	})
+
+	var onUpload, onDownload, onLoadEnd js.Func
+	onUpload = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		r.uploadProgress(progressOf(args[0]))
+		return nil
+	})
+	xhr.Get("upload").Call("addEventListener", "progress", onUpload)
+
+	// tooLarge is set when the download exceeds MaxBodySize.
+	var tooLarge error
+	if r.downloadProgress != nil || r.GetMaxBodySize() > 0 {
+		onDownload = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			loaded, total := progressOf(args[0])
+			if max := r.GetMaxBodySize(); max > 0 && (loaded > max || total > max) {
+				tooLarge = r.bodyTooLarge(fullURL)
+				xhr.Call("abort")
+				return nil
+			}
+			if r.downloadProgress != nil {
+				r.downloadProgress(loaded, total)
+			}
+			return nil
+		})
+		xhr.Call("addEventListener", "progress", onDownload)
+	}
+
+	// loadend fires once after load, error, timeout or abort.
+	onLoadEnd = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		stopAbort()
+		onUpload.Release()
+		if onDownload.Truthy() {
+			onDownload.Release()
+		}
+		onLoadEnd.Release()
+
+		if tooLarge != nil {
+			callback(nil, tooLarge)
+			return nil
+		}
+
+		// Status 0 means the request failed before any response.
+		status := xhr.Get("status").Int()
+		if status == 0 {
+			kind := KindNetwork
+			if args[0].Get("type").String() == "timeout" {
+				kind = KindTimeout
+			} else if urlOrigin(fullURL) != getOrigin() {
+				kind = KindCORSLikely
+			}
+			kind = errorKind(r, false, kind)
+			callback(nil, newError(kind, r, fullURL, Err("XMLHttpRequest", args[0].Get("type").String())))
+			return nil
+		}
+
+		response := &Response{
+			Status:     status,
+			Headers:    parseRawHeaders(xhr.Call("getAllResponseHeaders").String()),
+			RequestURL: fullURL,
+			Method:     r.method,
+		}
+		if data := xhr.Get("response"); data.Truthy() {
+			uint8Array := js.Global().Get("Uint8Array").New(data)
+			response.body = make([]byte, uint8Array.Get("length").Int())
+			js.CopyBytesToGo(response.body, uint8Array)
+		}
+		if r.stream {
+			// XMLHttpRequest buffers the body; it is served from memory.
+			response.SetBodyReader(io.NopCloser(bytes.NewReader(response.body)))
+		}
+		callback(response, nil)
+		return nil
+	})
+	xhr.Call("addEventListener", "loadend", onLoadEnd)
+
+	xhr.Call("send", jsBody)
+}
+
+// progressOf returns the bytes loaded by a ProgressEvent and its total,
+// or -1 when unknown.
+func progressOf(event js.Value) (loaded, total int64) {
+	total = -1
+	if event.Get("lengthComputable").Bool() {
+		total = int64(event.Get("total").Float())
+	}
+	return int64(event.Get("loaded").Float()), total
+}
+
+// parseRawHeaders parses the "key: value" CRLF-separated headers returned
+// by XMLHttpRequest.getAllResponseHeaders.
+func parseRawHeaders(raw string) []Header {
+	var headers []Header
+	for _, line := range Convert(raw).Split("\r\n") {
+		i := Index(line, ":")
+		if i <= 0 {
+			continue
+		}
+		headers = append(headers, Header{
+			Key:   Convert(line[:i]).TrimSpace().String(),
+			Value: Convert(line[i+1:]).TrimSpace().String(),
+		})
+	}
+	return headers
+}
+
+// errorKind classifies a failed browser request, returning fallback when
+// it was neither an abort nor a timeout.
+func errorKind(r *Request, timedOut bool, fallback ErrorKind) ErrorKind {
+	switch {
+	case r.ctx.Err() == context.Canceled:
+		return KindAborted
+	case timedOut || r.ctx.Err() == context.DeadlineExceeded:
+		return KindTimeout
+	}
+	return fallback
+}
+
+// jsStreamReader reads a streamed response body through the reader of its
+// ReadableStream. Read waits on promises, so it must not be called on the
+// JS event loop.
+type jsStreamReader struct {
+	reader  js.Value
+	chunk   []byte // unread rest of the last chunk
+	err     error  // io.EOF or the read failure
+	fail    func(jsErr js.Value) error
+	release func() // nil once closed
+}
+
+func (s *jsStreamReader) Read(p []byte) (int, error) {
+	for len(s.chunk) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		result, ok := await(s.reader.Call("read"))
+		if !ok {
+			s.err = s.fail(result)
+			continue
+		}
+		if result.Get("done").Bool() {
+			s.err = io.EOF
+			continue
+		}
+		value := result.Get("value")
+		s.chunk = make([]byte, value.Get("length").Int())
+		js.CopyBytesToGo(s.chunk, value)
+	}
+	n := copy(p, s.chunk)
+	s.chunk = s.chunk[n:]
+	return n, nil
+}
+
+func (s *jsStreamReader) Close() error {
+	if s.release == nil {
+		return nil
+	}
+	if s.err == nil {
+		s.reader.Call("cancel")
+		s.err = io.ErrClosedPipe
+	}
+	s.release()
+	s.release = nil
+	return nil
+}
+
+// await blocks until promise settles. ok is false when it was rejected,
+// in which case value is the rejection reason.
+func await(promise js.Value) (value js.Value, ok bool) {
+	type result struct {
+		value js.Value
+		ok    bool
+	}
+	settled := make(chan result, 1)
+	onResolve := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		settled <- result{args[0], true}
+		return nil
+	})
+	onReject := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		settled <- result{args[0], false}
+		return nil
+	})
+	defer onResolve.Release()
+	defer onReject.Release()
+
+	promise.Call("then", onResolve, onReject)
+	res := <-settled
+	return res.value, res.ok
+}
+
+// jsReadableStream wraps reader in a ReadableStream of Uint8Array chunks.
+// Reads run on a goroutine so a blocking reader never stalls the JS event
+// loop. release frees the JS callbacks once the request is complete.
+func jsReadableStream(reader io.Reader) (stream js.Value, release func()) {
+	buf := make([]byte, 32*1024)
+	var pull, cancel js.Func
+
+	pull = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		controller := args[0]
+		var executor js.Func
+		executor = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			resolve := args[0]
+			executor.Release()
+			go func() {
+				n, err := reader.Read(buf)
+				if n > 0 {
+					controller.Call("enqueue", jsBytes(buf[:n]))
+				}
+				if err == io.EOF {
+					controller.Call("close")
+				} else if err != nil {
+					controller.Call("error", js.Global().Get("Error").New(err.Error()))
+				}
+				resolve.Invoke()
+			}()
+			return nil
+		})
+		return js.Global().Get("Promise").New(executor)
+	})
+
+	cancel = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if c, ok := reader.(io.Closer); ok {
+			c.Close()
+		}
+		return nil
+	})
+
+	source := js.Global().Get("Object").New()
+	source.Set("pull", pull)
+	source.Set("cancel", cancel)
+	stream = js.Global().Get("ReadableStream").New(source)
+	return stream, func() {
+		pull.Release()
+		cancel.Release()
+	}
+}
+
+// requestStreams caches the result of supportsRequestStreams.
+var requestStreams struct{ checked, supported bool }
+
+// supportsRequestStreams reports whether fetch accepts ReadableStream
+// request bodies. Browsers without support ignore the duplex option and
+// stringify the stream, adding a text/plain Content-Type.
+func supportsRequestStreams() bool {
+	if requestStreams.checked {
+		return requestStreams.supported
+	}
+	requestStreams.checked = true
+	if js.Global().Get("ReadableStream").IsUndefined() {
+		return false
+	}
+	defer func() {
+		// The Request constructor throws on unsupported bodies.
+		if recover() != nil {
+			requestStreams.supported = false
+		}
+	}()
+
+	duplexAccessed := false
+	getter := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		duplexAccessed = true
+		return "half"
+	})
+	defer getter.Release()
+
+	init := js.Global().Get("Object").New()
+	init.Set("method", "POST")
+	init.Set("body", js.Global().Get("ReadableStream").New())
+	descriptor := js.Global().Get("Object").New()
+	descriptor.Set("get", getter)
+	descriptor.Set("enumerable", true)
+	js.Global().Get("Object").Call("defineProperty", init, "duplex", descriptor)
+
+	hasContentType := js.Global().Get("Request").New("", init).Get("headers").Call("has", "Content-Type").Bool()
+	requestStreams.supported = duplexAccessed && !hasContentType
+	return requestStreams.supported
+}
+
+// jsFormData builds a native FormData from m, with files as Blobs.
+func jsFormData(m *Multipart) js.Value {
+	form := js.Global().Get("FormData").New()
+	for _, p := range m.parts {
+		if p.Filename == "" {
+			form.Call("append", p.Name, string(p.Data))
+			continue
+		}
+		options := js.Global().Get("Object").New()
+		options.Set("type", p.ContentType)
+		parts := js.Global().Get("Array").New(jsBytes(p.Data))
+		blob := js.Global().Get("Blob").New(parts, options)
+		form.Call("append", p.Name, blob, p.Filename)
+	}
+	return form
+}
+
+// jsResponseHeaders copies the headers of a JS Response.
+func jsResponseHeaders(jsResp js.Value) []Header {
+	var headers []Header
+	iterator := jsResp.Get("headers").Call("entries")
+	for {
+		entry := iterator.Call("next")
+		if entry.Get("done").Bool() {
+			break
+		}
+		pair := entry.Get("value")
+		headers = append(headers, Header{
+			Key:   pair.Index(0).String(),
+			Value: pair.Index(1).String(),
+		})
+	}
+	return headers
+}
+
+// jsErrorMessage extracts a readable message from a rejected promise value.
+func jsErrorMessage(jsErr js.Value) string {
+	var errMsg string
+	if jsErr.Truthy() {
+		if jsErr.Type() == js.TypeString {
+			errMsg = jsErr.String()
+		} else if jsErr.Get("message").Type() == js.TypeString {
+			errMsg = jsErr.Get("message").String()
+		} else {
+			errMsg = jsErr.Call("toString").String()
+		}
+	}
+	if errMsg == "" {
+		errMsg = "unknown network error (possibly CORS, network unavailable, or invalid URL)"
+	}
+	return errMsg
+}
+
+// isTypeError reports whether the rejected value is a JS TypeError.
+func isTypeError(jsErr js.Value) bool {
+	return jsErr.Truthy() && jsErr.Type() == js.TypeObject &&
+		jsErr.InstanceOf(js.Global().Get("TypeError"))
+}
+
+// afterFunc calls fn after ms milliseconds using setTimeout, so waiting
+// never blocks the JS event loop. stop cancels the call.
+func afterFunc(ms int, fn func()) (stop func()) {
+	var done bool
+	var cb js.Func
+	cb = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		done = true
+		cb.Release()
+		fn()
+		return nil
+	})
+	id := js.Global().Call("setTimeout", cb, ms)
+	return func() {
+		if !done {
+			done = true
+			js.Global().Call("clearTimeout", id)
+			cb.Release()
+		}
+	}
+}
+
+// random returns a pseudo-random number in [0, 1).
+func random() float64 {
+	return js.Global().Get("Math").Call("random").Float()
+}
+
 func getOrigin() string {
 	return js.Global().Get("location").Get("origin").String()
 }
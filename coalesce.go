@@ -0,0 +1,168 @@
+package fetch
+
+import (
+	"context"
+	"sync"
+
+	. "github.com/tinywasm/fmt"
+)
+
+// SetCoalesce enables request coalescing on the default client.
+// See Client.SetCoalesce.
+func SetCoalesce(enabled bool) {
+	defaultClient.SetCoalesce(enabled)
+}
+
+// SetCoalesce makes concurrent identical GET, HEAD and OPTIONS requests of
+// the client share one in-flight request: same method, resolved URL,
+// headers, body size limit, timeout and retry policy. Every callback
+// receives its own copy of the response. Requests with a body, Stream or
+// progress callbacks are always sent on their own. Coalescing runs inside
+// the middleware, around the cache.
+//
+// Aborting one request only detaches its callback; the shared request is
+// cancelled once every request waiting on it was aborted. Likewise, the
+// Context deadline of a request only ends its own wait.
+func (c *Client) SetCoalesce(enabled bool) *Client {
+	if !enabled {
+		c.flights = nil
+	} else if c.flights == nil {
+		c.flights = &flightGroup{flights: make(map[string]*flight)}
+	}
+	return c
+}
+
+// flightGroup tracks the in-flight coalesced requests of a client.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is a shared request and the callbacks waiting on it.
+type flight struct {
+	cancel  context.CancelFunc
+	waiters []*waiter
+}
+
+type waiter struct {
+	callback func(*Response, error)
+	stop     func() bool // stops watching the waiter context
+}
+
+// handler wraps next to coalesce identical requests.
+func (g *flightGroup) handler(next Handler) Handler {
+	return func(r *Request, callback func(*Response, error)) {
+		if !coalescable(r) {
+			next(r, callback)
+			return
+		}
+		key := flightKey(r)
+		w := &waiter{callback: callback}
+
+		g.mu.Lock()
+		f, joined := g.flights[key]
+		var shared Request
+		if !joined {
+			// The shared request keeps the context values of the first
+			// caller but not its cancellation.
+			shared = *r
+			f = &flight{}
+			shared.ctx, f.cancel = context.WithCancel(context.WithoutCancel(r.ctx))
+			g.flights[key] = f
+		}
+		w.stop = context.AfterFunc(r.ctx, func() {
+			if g.leave(key, f, w) {
+				err := r.ctx.Err()
+				callback(nil, newError(contextErrorKind(err), r, r.url, err))
+			}
+		})
+		f.waiters = append(f.waiters, w)
+		g.mu.Unlock()
+		if joined {
+			return
+		}
+
+		next(&shared, func(resp *Response, err error) {
+			f.cancel()
+			for _, w := range g.finish(key, f) {
+				w.stop()
+				if resp != nil {
+					w.callback(resp.clone(), err)
+				} else {
+					w.callback(nil, err)
+				}
+			}
+		})
+	}
+}
+
+// leave detaches w from f, cancelling f when no waiter is left.
+// It reports false when f already completed.
+func (g *flightGroup) leave(key string, f *flight, w *waiter) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, other := range f.waiters {
+		if other == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			if len(f.waiters) == 0 {
+				f.cancel()
+				if g.flights[key] == f {
+					delete(g.flights, key)
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// finish removes f from the group and returns its waiters.
+func (g *flightGroup) finish(key string, f *flight) []*waiter {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+	waiters := f.waiters
+	f.waiters = nil
+	return waiters
+}
+
+// coalescable reports whether r may share an in-flight request.
+func coalescable(r *Request) bool {
+	switch r.method {
+	case "GET", "HEAD", "OPTIONS":
+	default:
+		return false
+	}
+	return len(r.body) == 0 && r.multipart == nil && r.bodyReader == nil && !r.stream &&
+		r.uploadProgress == nil && r.downloadProgress == nil
+}
+
+// flightKey identifies requests that may share a flight. It includes the
+// settings the shared request is sent with: body size limit, timeout and
+// retry policy.
+func flightKey(r *Request) string {
+	key := r.method + " " + r.url + "\n" + Convert(r.GetMaxBodySize()).String() +
+		" " + Convert(r.GetTimeout()).String() + "\n"
+	if p := r.retryPolicy(); p.MaxAttempts >= 2 {
+		key += Convert(p.MaxAttempts).String() + " " + Convert(p.BaseDelay).String() + " " +
+			Convert(p.MaxDelay).String() + " " + Convert(p.AllowNonIdempotent).String()
+		for _, status := range p.Statuses {
+			key += " " + Convert(status).String()
+		}
+	}
+	key += "\n"
+	for _, h := range r.GetHeaders() {
+		key += Convert(h.Key).ToLower().String() + ": " + h.Value + "\n"
+	}
+	return key
+}
+
+// clone returns a copy of r with its own headers and body.
+func (r *Response) clone() *Response {
+	c := *r
+	c.Headers = append([]Header(nil), r.Headers...)
+	c.body = append([]byte(nil), r.body...)
+	return &c
+}
@@ -0,0 +1,30 @@
+package fetch
+
+// Codec converts Go values to and from request and response bodies.
+// The core package ships no codec to stay dependency-free; see the
+// jsoncodec subpackage for JSON.
+type Codec interface {
+	Encode(v any) ([]byte, error)
+	Decode(data []byte, v any) error
+	ContentType() string
+}
+
+// Encode sets the request body to v encoded with codec, along with the
+// codec Content-Type. An encoding error fails the request when it is sent.
+func (r *Request) Encode(codec Codec, v any) *Request {
+	data, err := codec.Encode(v)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	return r.Body(data).Header("Content-Type", codec.ContentType())
+}
+
+// Decode decodes the response body into v with codec. The body of a
+// Stream response is read to the end and closed first.
+func (r *Response) Decode(codec Codec, v any) error {
+	if err := r.readStream(); err != nil {
+		return err
+	}
+	return codec.Decode(r.body, v)
+}
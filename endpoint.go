@@ -9,6 +9,13 @@ type EndpointProvider interface {
 	HandlerName() string
 }
 
+// PathParamProvider can be implemented by an EndpointProvider whose endpoint
+// is a template such as "/users/{id}/posts/{postID}", to fill placeholders
+// from its own fields. Values set with Request.Param take precedence.
+type PathParamProvider interface {
+	PathParam(name string) (value string, ok bool)
+}
+
 // resolveEndpoint extracts endpoint string from any (string or EndpointProvider)
 func resolveEndpoint(endpoint any) (string, error) {
 	if endpoint == nil {
@@ -25,17 +32,92 @@ func resolveEndpoint(endpoint any) (string, error) {
 	}
 }
 
-// buildFullURL constructs final URL using BaseURL + endpoint
-func buildFullURL(endpoint string, requestBaseURL string) (string, error) {
-	if isAbsoluteURL(endpoint) {
-		return endpoint, nil
+// expandPathParams replaces every "{name}" placeholder of endpoint with the
+// escaped value from params or, failing that, from provider (may be nil).
+// Only the part before the query or fragment is expanded, so braces there
+// (JSON filters, GraphQL queries) are left alone.
+func expandPathParams(endpoint string, params Values, provider PathParamProvider) (string, error) {
+	var rest string
+	for i := 0; i < len(endpoint); i++ {
+		if endpoint[i] == '?' || endpoint[i] == '#' {
+			endpoint, rest = endpoint[:i], endpoint[i:]
+			break
+		}
+	}
+	if Index(endpoint, "{") < 0 {
+		return endpoint + rest, nil
+	}
+	var buf []byte
+	for {
+		start := Index(endpoint, "{")
+		if start < 0 {
+			break
+		}
+		end := Index(endpoint[start:], "}")
+		if end < 0 {
+			return "", Err("unterminated path parameter in endpoint:", endpoint)
+		}
+		name := endpoint[start+1 : start+end]
+		value, ok := lookupParam(params, name)
+		if !ok && provider != nil {
+			value, ok = provider.PathParam(name)
+		}
+		// An empty value would drop the segment and address the parent
+		// resource instead.
+		if !ok || value == "" {
+			return "", Err("missing path parameter:", name)
+		}
+		// Browsers resolve "%2E%2E" like "..", so no escaping keeps
+		// dot segments from changing the path.
+		if value == "." || value == ".." {
+			return "", Err("path parameter cannot be a dot segment:", name)
+		}
+		buf = append(buf, endpoint[:start]...)
+		buf = appendPathSegment(buf, value)
+		endpoint = endpoint[start+end+1:]
+	}
+	return string(append(buf, endpoint...)) + rest, nil
+}
+
+// lookupParam returns the last value set for name.
+func lookupParam(params Values, name string) (string, bool) {
+	for i := len(params) - 1; i >= 0; i-- {
+		if params[i].Key == name {
+			return params[i].Value, true
+		}
+	}
+	return "", false
+}
+
+// appendPathSegment percent-encodes value as a single path segment, so "/"
+// is escaped.
+func appendPathSegment(buf []byte, value string) []byte {
+	return appendEscaped(buf, value, isUnreserved)
+}
+
+// buildFullURL resolves endpoint against the base URL following RFC 3986.
+// The request base URL wins over the client one, which wins over the origin.
+//
+// Unlike plain RFC 3986 resolution, the base path is kept as a prefix:
+// "/users" against "https://api.x.com/v2/" gives "https://api.x.com/v2/users".
+// Trailing slashes are preserved, dot segments are removed, "//host/path"
+// takes the scheme of the base and the base query is merged before the
+// endpoint query.
+func buildFullURL(endpoint, requestBaseURL, clientBaseURL string) (string, error) {
+	ref := parseURI(endpoint)
+	if ref.scheme != "" {
+		if err := checkHTTPURI(ref); err != nil {
+			return "", err
+		}
+		ref.path = removeDotSegments(ref.path)
+		return ref.String(), nil
 	}
 
 	var base string
 	if requestBaseURL != "" {
 		base = requestBaseURL
-	} else if defaultBaseURL != "" {
-		base = defaultBaseURL
+	} else if clientBaseURL != "" {
+		base = clientBaseURL
 	} else {
 		base = getOrigin()
 	}
@@ -44,20 +126,69 @@ func buildFullURL(endpoint string, requestBaseURL string) (string, error) {
 		return "", Err("BaseURL not set, provide absolute URL or call SetBaseURL()")
 	}
 
-	return joinURLPath(base, endpoint), nil
+	b := parseURI(base)
+	if b.scheme == "" {
+		return "", Err("base URL must be absolute:", base)
+	}
+	if err := checkHTTPURI(b); err != nil {
+		return "", err
+	}
+
+	return resolveURI(b, ref).String(), nil
 }
 
-// isAbsoluteURL checks if URL starts with "http://" or "https://"
-func isAbsoluteURL(url string) bool {
-	return HasPrefix(url, "http://") || HasPrefix(url, "https://")
+// checkHTTPURI validates that an absolute URI can be fetched.
+func checkHTTPURI(u uri) error {
+	if u.scheme != "http" && u.scheme != "https" {
+		return Err("unsupported URL scheme:", u.scheme)
+	}
+	if !u.hasAuthority || u.authority == "" {
+		return Err("URL has no host:", u.String())
+	}
+	return nil
+}
+
+// resolveURI resolves ref against the absolute base (RFC 3986, section 5.2.2),
+// treating the base path as a prefix of the reference path.
+func resolveURI(base, ref uri) uri {
+	target := uri{scheme: base.scheme, hasAuthority: true}
+
+	if ref.hasAuthority {
+		// Protocol-relative reference: "//host/path".
+		target.authority = ref.authority
+		target.path = removeDotSegments(ref.path)
+		target.query, target.hasQuery = ref.query, ref.hasQuery
+	} else {
+		target.authority = base.authority
+		target.path = base.path
+		if ref.path != "" {
+			target.path = removeDotSegments(joinPaths(base.path, ref.path))
+		}
+		target.query, target.hasQuery = mergeQuery(base, ref)
+	}
+
+	target.fragment, target.hasFragment = ref.fragment, ref.hasFragment
+	return target
+}
+
+// joinPaths appends ref to the base path prefix with a single slash between them.
+func joinPaths(base, ref string) string {
+	for HasSuffix(base, "/") {
+		base = base[:len(base)-1]
+	}
+	for HasPrefix(ref, "/") {
+		ref = ref[1:]
+	}
+	return base + "/" + ref
 }
 
-// joinURLPath joins base and path, normalizing slashes
-func joinURLPath(base, path string) string {
-	if path == "" {
-		return base
+// mergeQuery returns the base query followed by the reference query.
+func mergeQuery(base, ref uri) (string, bool) {
+	switch {
+	case !ref.hasQuery || ref.query == "":
+		return base.query, base.hasQuery || ref.hasQuery
+	case !base.hasQuery || base.query == "":
+		return ref.query, true
 	}
-	// Use PathJoin for normalization. PathJoin is designed for file paths but
-	// since URLs use '/' it works well for simple joins.
-	return PathJoin(base, path).String()
+	return base.query + "&" + ref.query, true
 }
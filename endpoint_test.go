@@ -15,6 +15,25 @@ func (m MockUser) HandlerName() string {
 	return "/post_json"
 }
 
+type MockPost struct {
+	UserID string
+	ID     string
+}
+
+func (m MockPost) HandlerName() string {
+	return "/path/users/{id}/posts/{postID}"
+}
+
+func (m MockPost) PathParam(name string) (string, bool) {
+	switch name {
+	case "id":
+		return m.UserID, true
+	case "postID":
+		return m.ID, true
+	}
+	return "", false
+}
+
 func TestEndpointResolution(t *testing.T) {
 	t.Run("String endpoint", func(t *testing.T) {
 		req := fetch.Get("/users")
@@ -0,0 +1,104 @@
+package fetch
+
+import (
+	. "github.com/tinywasm/fmt"
+)
+
+// ErrorKind classifies why a request failed.
+type ErrorKind uint8
+
+const (
+	KindBuild        ErrorKind = iota + 1 // the URL or request could not be built
+	KindNetwork                           // the server could not be reached
+	KindTimeout                           // Timeout or the context deadline expired
+	KindAborted                           // Call.Abort or context cancellation
+	KindCORSLikely                        // the browser blocked a cross-origin request
+	KindBodyRead                          // the response body could not be read
+	KindBodyTooLarge                      // the response body exceeded MaxBodySize
+)
+
+// String returns the name of the kind.
+func (k ErrorKind) String() string {
+	switch k {
+	case KindBuild:
+		return "build"
+	case KindNetwork:
+		return "network"
+	case KindTimeout:
+		return "timeout"
+	case KindAborted:
+		return "aborted"
+	case KindCORSLikely:
+		return "cors"
+	case KindBodyRead:
+		return "body read"
+	case KindBodyTooLarge:
+		return "body too large"
+	}
+	return "unknown"
+}
+
+// Error is the error passed to callbacks when a request fails.
+// Use errors.Is with the Err* sentinels to check the kind, or errors.As
+// to access the method and URL.
+type Error struct {
+	Kind   ErrorKind
+	Method string
+	URL    string
+	Err    error // underlying cause, may be nil
+}
+
+// Sentinel errors matching any *Error of the same kind with errors.Is.
+var (
+	ErrBuild        = &Error{Kind: KindBuild}
+	ErrNetwork      = &Error{Kind: KindNetwork}
+	ErrTimeout      = &Error{Kind: KindTimeout}
+	ErrAborted      = &Error{Kind: KindAborted}
+	ErrCORSLikely   = &Error{Kind: KindCORSLikely}
+	ErrBodyRead     = &Error{Kind: KindBodyRead}
+	ErrBodyTooLarge = &Error{Kind: KindBodyTooLarge}
+)
+
+// Error formats the error as "fetch: <kind>: <method> <url>: <cause>".
+func (e *Error) Error() string {
+	msg := "fetch: " + e.Kind.String()
+	if e.Method != "" || e.URL != "" {
+		msg += ": " + e.Method
+		if e.URL != "" {
+			msg += " " + e.URL
+		}
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying cause.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error of the same kind.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind
+}
+
+// newError creates an *Error for the request r.
+func newError(kind ErrorKind, r *Request, url string, err error) *Error {
+	return &Error{Kind: kind, Method: r.method, URL: url, Err: err}
+}
+
+// HTTPError is the error passed to callbacks for non-2xx responses when
+// FailOnHTTPError is enabled. It carries the full response, so the error
+// payload sent by the server can still be inspected.
+type HTTPError struct {
+	Response *Response
+}
+
+// Error formats the error as "fetch: <method> <url>: status <code>".
+func (e *HTTPError) Error() string {
+	return "fetch: " + e.Response.Method + " " + e.Response.RequestURL +
+		": status " + Convert(e.Response.Status).String()
+}
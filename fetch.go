@@ -1,6 +1,10 @@
 package fetch
 
 import (
+	"context"
+	"io"
+	"sync"
+
 	. "github.com/tinywasm/fmt"
 )
 
@@ -12,12 +16,29 @@ type Header struct {
 
 // Request represents an HTTP request builder.
 type Request struct {
-	method   string
-	endpoint any
-	baseURL  string // per-request override
-	headers  []Header
-	body     []byte
-	timeout  int
+	client      *Client
+	method      string
+	endpoint    any
+	baseURL     string // per-request override
+	headers     []Header
+	query       Values
+	params      Values
+	body        []byte
+	multipart   *Multipart
+	bodyReader  io.Reader
+	bodySize    int64
+	timeout     int
+	maxBodySize int64
+	stream      bool
+	ctx         context.Context
+	url         string // resolved by Send
+	err         error  // builder error, reported by Send
+
+	failOnHTTPError  bool
+	retry            RetryPolicy
+	uploadProgress   func(sent, total int64)
+	downloadProgress func(received, total int64)
+	priority         Priority
 }
 
 // Response represents an HTTP response.
@@ -27,26 +48,48 @@ type Response struct {
 	RequestURL string
 	Method     string
 	body       []byte
+	stream     io.ReadCloser // body of a Stream request, see BodyReader
 }
 
 // Get creates a new GET request.
 func Get(endpoint any) *Request {
-	return &Request{method: "GET", endpoint: endpoint}
+	return defaultClient.Get(endpoint)
 }
 
 // Post creates a new POST request.
 func Post(endpoint any) *Request {
-	return &Request{method: "POST", endpoint: endpoint}
+	return defaultClient.Post(endpoint)
 }
 
 // Put creates a new PUT request.
 func Put(endpoint any) *Request {
-	return &Request{method: "PUT", endpoint: endpoint}
+	return defaultClient.Put(endpoint)
 }
 
 // Delete creates a new DELETE request.
 func Delete(endpoint any) *Request {
-	return &Request{method: "DELETE", endpoint: endpoint}
+	return defaultClient.Delete(endpoint)
+}
+
+// Patch creates a new PATCH request.
+func Patch(endpoint any) *Request {
+	return defaultClient.Patch(endpoint)
+}
+
+// Head creates a new HEAD request.
+func Head(endpoint any) *Request {
+	return defaultClient.Head(endpoint)
+}
+
+// Options creates a new OPTIONS request.
+func Options(endpoint any) *Request {
+	return defaultClient.Options(endpoint)
+}
+
+// Method creates a new request with an arbitrary HTTP method.
+// The verb is sent as given: methods are case-sensitive.
+func Method(verb string, endpoint any) *Request {
+	return defaultClient.Method(verb, endpoint)
 }
 
 // BaseURL sets a per-request base URL override.
@@ -55,6 +98,14 @@ func (r *Request) BaseURL(url string) *Request {
 	return r
 }
 
+// Param sets the value of the "{name}" placeholder in the endpoint template,
+// e.g. Get("/users/{id}").Param("id", "123"). The value is escaped as a
+// single path segment.
+func (r *Request) Param(name, value string) *Request {
+	r.params = r.params.Add(name, value)
+	return r
+}
+
 // Header adds a header to the request.
 func (r *Request) Header(key, value string) *Request {
 	r.headers = append(r.headers, Header{Key: key, Value: value})
@@ -89,37 +140,173 @@ func (r *Request) ContentTypeHTML() *Request {
 // Body sets the request body.
 func (r *Request) Body(data []byte) *Request {
 	r.body = data
+	r.multipart = nil
+	r.bodyReader = nil
 	return r
 }
 
 // Timeout sets the request timeout in milliseconds.
+// It overrides the client timeout.
 func (r *Request) Timeout(ms int) *Request {
 	r.timeout = ms
 	return r
 }
 
+// FailOnHTTPError makes the request report non-2xx responses as an
+// *HTTPError instead of a successful response.
+func (r *Request) FailOnHTTPError() *Request {
+	r.failOnHTTPError = true
+	return r
+}
+
+// Context sets the context of the request. Cancelling it aborts the request
+// and its deadline applies together with Timeout.
+func (r *Request) Context(ctx context.Context) *Request {
+	r.ctx = ctx
+	return r
+}
+
 // Send executes the request and calls the callback with the response.
-func (r *Request) Send(callback func(*Response, error)) {
-	doRequest(r, callback)
+// The callback is called exactly once. The returned Call aborts the request.
+func (r *Request) Send(callback func(*Response, error)) *Call {
+	parent := r.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+
+	// Each Send works on its own copy so a Request can be sent again.
+	// The headers are capped so middleware appends never alias r.headers.
+	req := *r
+	req.ctx = ctx
+	req.headers = r.headers[:len(r.headers):len(r.headers)]
+	req.query = r.query[:len(r.query):len(r.query)]
+
+	failOnHTTPError := r.failOnHTTPError || r.client.failOnHTTPError
+
+	var once sync.Once
+	done := func(resp *Response, err error) {
+		once.Do(func() {
+			if err == nil && failOnHTTPError && (resp.Status < 200 || resp.Status > 299) {
+				// The error payload of a streamed response is buffered.
+				resp.readStream()
+				resp, err = nil, &HTTPError{Response: resp}
+			}
+			if resp != nil && resp.stream != nil {
+				// A streamed body keeps the request alive until it is closed.
+				resp.stream = &releasingBody{ReadCloser: resp.stream, release: cancel}
+			} else {
+				cancel()
+			}
+			callback(resp, err)
+		})
+	}
+
+	url, err := buildURL(r)
+	if r.err != nil {
+		err = r.err
+	}
+	if err != nil {
+		done(nil, newError(KindBuild, r, "", err))
+		return &Call{cancel: cancel}
+	}
+	req.url = url
+
+	r.client.chain(sendWithRetry)(&req, done)
+	return &Call{cancel: cancel}
+}
+
+// Do executes the request and blocks until the response is available.
+// It shares URL resolution, headers, timeout and context handling with Send.
+// In WASM, call it from a goroutine: blocking inside a js.FuncOf callback
+// stalls the JS event loop that delivers the response.
+func (r *Request) Do() (*Response, error) {
+	done := make(chan struct{})
+	var resp *Response
+	var err error
+	r.Send(func(res *Response, e error) {
+		resp, err = res, e
+		close(done)
+	})
+	<-done
+	return resp, err
 }
 
-// Dispatch executes the request and sends the response to the global handler.
+// Dispatch executes the request and sends the response to the client handler.
 // This is a fire-and-forget method.
-func (r *Request) Dispatch() {
-	if globalHandler == nil {
-		log("Dispatch called but no global handler set")
-		return
+func (r *Request) Dispatch() *Call {
+	c := r.client
+	if c.handler == nil {
+		c.log("Dispatch called but no handler set")
+		return &Call{cancel: func() {}}
 	}
-	doRequest(r, func(resp *Response, err error) {
+	return r.Send(func(resp *Response, err error) {
 		if err != nil {
-			log("Dispatch error:", err)
+			c.log("Dispatch error:", err)
 			return
 		}
-		globalHandler(resp)
+		c.handler(resp)
 	})
 }
 
+// GetMethod returns the HTTP method of the request.
+func (r *Request) GetMethod() string {
+	return r.method
+}
+
+// GetURL returns the resolved request URL.
+// It is empty until the request is sent, so it is meant for middleware
+// and transports.
+func (r *Request) GetURL() string {
+	return r.url
+}
+
+// GetBody returns the request body.
+func (r *Request) GetBody() []byte {
+	return r.body
+}
+
+// GetContext returns the request context. While the request is being sent
+// it is cancelled by Call.Abort.
+func (r *Request) GetContext() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// GetHeaders returns the client default headers followed by the request
+// headers. Defaults overridden by the request are left out.
+func (r *Request) GetHeaders() []Header {
+	if len(r.client.headers) == 0 {
+		return r.headers
+	}
+	headers := make([]Header, 0, len(r.client.headers)+len(r.headers))
+	for _, h := range r.client.headers {
+		if !hasHeader(r.headers, h.Key) {
+			headers = append(headers, h)
+		}
+	}
+	return append(headers, r.headers...)
+}
+
+// GetTimeout returns the request timeout in milliseconds, falling back to
+// the client one. Zero means no timeout.
+func (r *Request) GetTimeout() int {
+	if r.timeout > 0 {
+		return r.timeout
+	}
+	return r.client.timeout
+}
+
+// SetBody replaces the response body, e.g. from a Middleware.
+func (r *Response) SetBody(data []byte) *Response {
+	r.body = data
+	return r
+}
+
 // Body returns the response body as a byte slice.
+// It is empty for requests sent with Stream, see BodyReader.
 func (r *Response) Body() []byte {
 	return r.body
 }
@@ -132,11 +319,30 @@ func (r *Response) Text() string {
 // GetHeader returns the value of the specified header.
 // It is case-insensitive.
 func (r *Response) GetHeader(key string) string {
-	key = Convert(key).ToLower().String()
 	for _, h := range r.Headers {
-		if Convert(h.Key).ToLower().String() == key {
+		if equalFold(h.Key, key) {
 			return h.Value
 		}
 	}
 	return ""
 }
+
+// bodyless reports whether a response to method with status never has a body.
+func bodyless(method string, status int) bool {
+	return method == "HEAD" || status == 204 || status == 304 || (status >= 100 && status < 200)
+}
+
+// hasHeader reports whether headers contains key (case-insensitive).
+func hasHeader(headers []Header, key string) bool {
+	for _, h := range headers {
+		if equalFold(h.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalFold reports whether a and b are equal ignoring case.
+func equalFold(a, b string) bool {
+	return Convert(a).ToLower().String() == Convert(b).ToLower().String()
+}
@@ -1,10 +1,17 @@
 package fetch_test
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/tinywasm/fetch"
+	"github.com/tinywasm/fetch/jsoncodec"
 )
 
 func SendRequest_GetShared(t *testing.T, baseURL string) {
@@ -239,3 +246,1181 @@ func SendRequest_DispatchShared(t *testing.T, baseURL string) {
 		t.Error("Dispatch global handler timeout")
 	}
 }
+
+func SendRequest_ClientShared(t *testing.T, baseURL string) {
+	api := fetch.NewClient().
+		SetBaseURL(baseURL).
+		SetHeader("X-Custom", "client-value").
+		SetTimeout(2000)
+	other := fetch.NewClient().SetBaseURL("http://invalid.invalid")
+
+	if other.GetBaseURL() == api.GetBaseURL() {
+		t.Fatal("Clients should not share the base URL")
+	}
+
+	done := make(chan bool)
+	var resp *fetch.Response
+	var responseErr error
+
+	api.Get("/headers").Send(func(r *fetch.Response, err error) {
+		resp, responseErr = r, err
+		done <- true
+	})
+	<-done
+
+	if responseErr != nil {
+		t.Fatalf("Expected no error, got %v", responseErr)
+	}
+	if got := resp.GetHeader("X-Reflected-X-Custom"); got != "client-value" {
+		t.Errorf("Expected default header 'client-value', got '%s'", got)
+	}
+
+	// Request headers override client defaults.
+	api.Get("/headers").
+		Header("X-Custom", "request-value").
+		Send(func(r *fetch.Response, err error) {
+			resp, responseErr = r, err
+			done <- true
+		})
+	<-done
+
+	if responseErr != nil {
+		t.Fatalf("Expected no error, got %v", responseErr)
+	}
+	if got := resp.GetHeader("X-Reflected-X-Custom"); got != "request-value" {
+		t.Errorf("Expected overridden header 'request-value', got '%s'", got)
+	}
+
+	// The client handler is used by Dispatch instead of the global one.
+	api.SetHandler(func(r *fetch.Response) {
+		if r.RequestURL == baseURL+"/get" {
+			done <- true
+		}
+	})
+	api.Get("/get").Dispatch()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 2):
+		t.Error("Dispatch client handler timeout")
+	}
+}
+
+func SendRequest_AbortShared(t *testing.T, baseURL string) {
+	calls := make(chan error, 2)
+
+	call := fetch.Get(baseURL + "/timeout").Send(func(resp *fetch.Response, err error) {
+		calls <- err
+	})
+	call.Abort()
+	call.Abort() // second abort is a no-op
+
+	select {
+	case err := <-calls:
+		if !errors.Is(err, fetch.ErrAborted) {
+			t.Fatalf("Expected ErrAborted, got %v", err)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("Aborted request callback was not called")
+	}
+
+	select {
+	case err := <-calls:
+		t.Errorf("Callback called more than once, second error: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Aborting a completed request does not call the callback again.
+	call = fetch.Get(baseURL + "/get").Send(func(resp *fetch.Response, err error) {
+		calls <- err
+	})
+	if err := <-calls; err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	call.Abort()
+	select {
+	case err := <-calls:
+		t.Errorf("Callback called after completion, error: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func SendRequest_ContextShared(t *testing.T, baseURL string) {
+	result := make(chan error, 1)
+
+	// A context deadline shorter than the server delay fails the request.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	fetch.Get(baseURL + "/timeout").
+		Context(ctx).
+		Send(func(resp *fetch.Response, err error) {
+			result <- err
+		})
+	if err := <-result; err == nil {
+		t.Fatal("Expected context deadline to fail the request")
+	}
+
+	// Cancelling the context aborts the request.
+	ctx, cancel = context.WithCancel(context.Background())
+	fetch.Get(baseURL + "/timeout").
+		Context(ctx).
+		Send(func(resp *fetch.Response, err error) {
+			result <- err
+		})
+	cancel()
+	if err := <-result; !errors.Is(err, fetch.ErrAborted) {
+		t.Fatalf("Expected ErrAborted after cancel, got %v", err)
+	}
+
+	// A live context does not interfere with the request.
+	fetch.Get(baseURL + "/get").
+		Context(context.Background()).
+		Send(func(resp *fetch.Response, err error) {
+			result <- err
+		})
+	if err := <-result; err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func SendRequest_DoShared(t *testing.T, baseURL string) {
+	resp, err := fetch.Get(baseURL + "/get").Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.Text() != "get success" {
+		t.Errorf("Expected body 'get success', got '%s'", resp.Text())
+	}
+
+	resp, err = fetch.Post(baseURL + "/post_json").
+		ContentTypeJSON().
+		Body([]byte(`{"message":"hello"}`)).
+		Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.Text() != `{"message":"hello"}` {
+		t.Errorf("Expected echoed JSON, got '%s'", resp.Text())
+	}
+
+	if _, err := fetch.Get(baseURL + "/timeout").Timeout(10).Do(); err == nil {
+		t.Error("Expected timeout error from Do")
+	}
+
+	if _, err := fetch.Get(nil).Do(); err == nil {
+		t.Error("Expected error for nil endpoint")
+	}
+}
+
+func SendRequest_ErrorKindsShared(t *testing.T, baseURL string) {
+	_, err := fetch.Get(baseURL + "/timeout").Timeout(10).Do()
+	if !errors.Is(err, fetch.ErrTimeout) {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+	var fetchErr *fetch.Error
+	if !errors.As(err, &fetchErr) {
+		t.Fatalf("Expected *fetch.Error, got %T", err)
+	}
+	if fetchErr.Kind != fetch.KindTimeout || fetchErr.Method != "GET" || fetchErr.URL != baseURL+"/timeout" {
+		t.Errorf("Unexpected error fields: %+v", fetchErr)
+	}
+	if errors.Is(err, fetch.ErrAborted) {
+		t.Error("Timeout error must not match ErrAborted")
+	}
+
+	_, err = fetch.Get(nil).Do()
+	if !errors.Is(err, fetch.ErrBuild) {
+		t.Errorf("Expected ErrBuild, got %v", err)
+	}
+
+	// Nothing listens on port 1: a network failure, reported as a likely
+	// CORS block by browsers since the request is cross-origin.
+	_, err = fetch.Get("http://127.0.0.1:1/unreachable").Do()
+	if !errors.Is(err, fetch.ErrNetwork) && !errors.Is(err, fetch.ErrCORSLikely) {
+		t.Errorf("Expected ErrNetwork or ErrCORSLikely, got %v", err)
+	}
+}
+
+func SendRequest_FailOnHTTPErrorShared(t *testing.T, baseURL string) {
+	resp, err := fetch.Get(baseURL + "/error").FailOnHTTPError().Do()
+	if resp != nil {
+		t.Error("Expected nil response with HTTPError")
+	}
+	var httpErr *fetch.HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("Expected *fetch.HTTPError, got %v", err)
+	}
+	if httpErr.Response.Status != 500 {
+		t.Errorf("Expected status 500, got %d", httpErr.Response.Status)
+	}
+	if httpErr.Response.Text() != "internal server error\n" {
+		t.Errorf("Expected server payload, got '%s'", httpErr.Response.Text())
+	}
+
+	// Client-wide option, successful responses are unaffected.
+	api := fetch.NewClient().SetBaseURL(baseURL).SetFailOnHTTPError(true)
+	if _, err := api.Get("/get").Do(); err != nil {
+		t.Errorf("Expected no error for 200, got %v", err)
+	}
+	if _, err := api.Get("/error").Do(); !errors.As(err, &httpErr) {
+		t.Errorf("Expected *fetch.HTTPError from client option, got %v", err)
+	}
+}
+
+// uniqueID returns an identifier for server endpoints keeping per-test state.
+func uniqueID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+func SendRequest_RetryShared(t *testing.T, baseURL string) {
+	policy := fetch.RetryPolicy{MaxAttempts: 3, BaseDelay: 10}
+
+	// Two 503 responses followed by a success on the third attempt.
+	resp, err := fetch.Get(baseURL + "/flaky?fail=2&id=" + uniqueID()).Retry(policy).Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.Status != 200 || resp.Text() != "3" {
+		t.Errorf("Expected success on attempt 3, got status %d body '%s'", resp.Status, resp.Text())
+	}
+
+	// Attempts exhausted: the last response is returned.
+	resp, err = fetch.Get(baseURL + "/flaky?fail=5&id=" + uniqueID()).Retry(policy).Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.Status != 503 {
+		t.Errorf("Expected status 503 after exhausting attempts, got %d", resp.Status)
+	}
+
+	// Non-idempotent methods are not retried unless allowed.
+	resp, err = fetch.Post(baseURL + "/flaky?fail=1&id=" + uniqueID()).Retry(policy).Do()
+	if err != nil || resp.Status != 503 {
+		t.Errorf("Expected POST not to be retried, got %v / %v", resp, err)
+	}
+	policy.AllowNonIdempotent = true
+	resp, err = fetch.Post(baseURL + "/flaky?fail=1&id=" + uniqueID()).Retry(policy).Do()
+	if err != nil || resp.Status != 200 {
+		t.Errorf("Expected POST to be retried when allowed, got %v / %v", resp, err)
+	}
+
+	// Retry-After takes precedence over the backoff delay.
+	slow := fetch.RetryPolicy{MaxAttempts: 3, BaseDelay: 5000, MaxDelay: 5000}
+	start := time.Now()
+	resp, err = fetch.Get(baseURL + "/flaky?fail=1&retry_after=0&id=" + uniqueID()).Retry(slow).Do()
+	if err != nil || resp.Status != 200 {
+		t.Errorf("Expected success after Retry-After, got %v / %v", resp, err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Retry-After: 0 was not honoured, took %v", time.Since(start))
+	}
+
+	// Aborting while waiting for the next attempt reports ErrAborted.
+	result := make(chan error, 1)
+	call := fetch.Get(baseURL + "/flaky?fail=5&id=" + uniqueID()).
+		Retry(slow).
+		Send(func(resp *fetch.Response, err error) { result <- err })
+	time.Sleep(100 * time.Millisecond)
+	call.Abort()
+	select {
+	case err := <-result:
+		if !errors.Is(err, fetch.ErrAborted) {
+			t.Errorf("Expected ErrAborted during backoff, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Abort did not interrupt the retry backoff")
+	}
+}
+
+func SendRequest_MiddlewareShared(t *testing.T, baseURL string) {
+	var order []string
+	trace := func(name string) fetch.Middleware {
+		return func(next fetch.Handler) fetch.Handler {
+			return func(r *fetch.Request, callback func(*fetch.Response, error)) {
+				order = append(order, name+":"+r.GetMethod()+" "+r.GetURL())
+				next(r, callback)
+			}
+		}
+	}
+	auth := func(next fetch.Handler) fetch.Handler {
+		return func(r *fetch.Request, callback func(*fetch.Response, error)) {
+			next(r.Header("X-Custom", "from-middleware"), callback)
+		}
+	}
+	rewrite := func(next fetch.Handler) fetch.Handler {
+		return func(r *fetch.Request, callback func(*fetch.Response, error)) {
+			next(r, func(resp *fetch.Response, err error) {
+				if err == nil {
+					resp.SetBody([]byte("rewritten: " + resp.Text()))
+				}
+				callback(resp, err)
+			})
+		}
+	}
+
+	api := fetch.NewClient().SetBaseURL(baseURL).Use(trace("outer"), auth, rewrite, trace("inner"))
+	resp, err := api.Get("/headers").Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := resp.GetHeader("X-Reflected-X-Custom"); got != "from-middleware" {
+		t.Errorf("Expected header injected by middleware, got '%s'", got)
+	}
+	if resp.Text() != "rewritten: headers ok" {
+		t.Errorf("Expected rewritten body, got '%s'", resp.Text())
+	}
+	want := "GET " + baseURL + "/headers"
+	if len(order) != 2 || order[0] != "outer:"+want || order[1] != "inner:"+want {
+		t.Errorf("Unexpected middleware order: %v", order)
+	}
+
+	// Short-circuit: the network is never reached.
+	cached := fetch.NewClient().Use(func(next fetch.Handler) fetch.Handler {
+		return func(r *fetch.Request, callback func(*fetch.Response, error)) {
+			callback((&fetch.Response{Status: 200, RequestURL: r.GetURL()}).SetBody([]byte("cached")), nil)
+		}
+	})
+	resp, err = cached.Get("http://127.0.0.1:1/unreachable").Do()
+	if err != nil || resp.Text() != "cached" {
+		t.Errorf("Expected short-circuited response, got %v / %v", resp, err)
+	}
+
+	// Middleware of one client does not affect others.
+	resp, err = fetch.Get(baseURL + "/get").Do()
+	if err != nil || resp.Text() != "get success" {
+		t.Errorf("Default client should not run other clients' middleware, got %v / %v", resp, err)
+	}
+}
+
+func SendRequest_TransportShared(t *testing.T, baseURL string) {
+	var seen []string
+	fake := fetch.Handler(func(r *fetch.Request, callback func(*fetch.Response, error)) {
+		for _, h := range r.GetHeaders() {
+			seen = append(seen, h.Key+"="+h.Value)
+		}
+		resp := &fetch.Response{Status: 201, RequestURL: r.GetURL(), Method: r.GetMethod()}
+		callback(resp.SetBody(r.GetBody()), nil)
+	})
+
+	api := fetch.NewClient().
+		SetBaseURL("http://backend.invalid").
+		SetHeader("X-Default", "1").
+		SetTransport(fake)
+	resp, err := api.Post("/echo").Header("X-Custom", "2").Body([]byte("payload")).Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.Status != 201 || resp.Text() != "payload" || resp.RequestURL != "http://backend.invalid/echo" {
+		t.Errorf("Unexpected response from fake transport: %+v %s", resp, resp.Text())
+	}
+	if len(seen) != 2 || seen[0] != "X-Default=1" || seen[1] != "X-Custom=2" {
+		t.Errorf("Unexpected headers seen by transport: %v", seen)
+	}
+
+	// nil restores the platform transport.
+	resp, err = api.SetTransport(nil).SetBaseURL(baseURL).Get("/get").Do()
+	if err != nil || resp.Text() != "get success" {
+		t.Errorf("Expected default transport response, got %v / %v", resp, err)
+	}
+}
+
+func SendRequest_MethodsShared(t *testing.T, baseURL string) {
+	resp, err := fetch.Patch(baseURL + "/method").Body([]byte("partial")).Do()
+	if err != nil || resp.Text() != "PATCH" {
+		t.Errorf("PATCH failed: %v / %v", resp, err)
+	}
+
+	resp, err = fetch.Method("REPORT", baseURL+"/method").Do()
+	if err != nil || resp.Text() != "REPORT" {
+		t.Errorf("Custom method failed: %v / %v", resp, err)
+	}
+
+	resp, err = fetch.Head(baseURL + "/method").Do()
+	if err != nil {
+		t.Fatalf("HEAD failed: %v", err)
+	}
+	if resp.Status != 200 || resp.GetHeader("X-Method") != "HEAD" || len(resp.Body()) != 0 {
+		t.Errorf("Unexpected HEAD response: %d %v '%s'", resp.Status, resp.Headers, resp.Text())
+	}
+
+	resp, err = fetch.Options(baseURL + "/method").Do()
+	if err != nil || resp.Status != 200 {
+		t.Errorf("OPTIONS failed: %v / %v", resp, err)
+	}
+
+	resp, err = fetch.Delete(baseURL + "/no_content").Do()
+	if err != nil {
+		t.Fatalf("204 request failed: %v", err)
+	}
+	if resp.Status != 204 || len(resp.Body()) != 0 {
+		t.Errorf("Unexpected 204 response: %d '%s'", resp.Status, resp.Text())
+	}
+}
+
+func SendRequest_QueryShared(t *testing.T, baseURL string) {
+	resp, err := fetch.Get(baseURL+"/query?a=1").
+		Query("q", "two words & more").
+		Query("tag", "x").
+		QueryValues(fetch.Values{}.Add("tag", "y").Add("é", "100%")).
+		Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := "a=1&q=two%20words%20%26%20more&tag=x&tag=y&%C3%A9=100%25"
+	if resp.Text() != want {
+		t.Errorf("Expected query '%s', got '%s'", want, resp.Text())
+	}
+
+	// Merged with a query string in the base URL.
+	api := fetch.NewClient().SetBaseURL(baseURL + "?key=k")
+	resp, err = api.Get("/query?page=2").Query("q", "1").Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.Text() != "key=k&page=2&q=1" {
+		t.Errorf("Expected merged query 'key=k&page=2&q=1', got '%s'", resp.Text())
+	}
+
+	if got := (fetch.Values{{Key: "a", Value: "1"}, {Key: "a", Value: "2"}}).Encode(); got != "a=1&a=2" {
+		t.Errorf("Expected 'a=1&a=2', got '%s'", got)
+	}
+}
+
+func SendRequest_URLResolutionShared(t *testing.T, baseURL string) {
+	cases := []struct {
+		base, endpoint, path string
+	}{
+		{baseURL + "/path/v2/", "/users", "/path/v2/users"},
+		{baseURL + "/path/v2", "users/", "/path/v2/users/"},
+		{baseURL + "/path/v2/", "/a/./b/../c", "/path/v2/a/c"},
+		{"http://invalid.invalid", "//" + strings.TrimPrefix(baseURL, "http://") + "/path/cdn", "/path/cdn"},
+		{"http://invalid.invalid", strings.Replace(baseURL, "http://", "HTTP://", 1) + "/path/upper", "/path/upper"},
+	}
+	for _, c := range cases {
+		resp, err := fetch.NewClient().SetBaseURL(c.base).Get(c.endpoint).Do()
+		if err != nil {
+			t.Errorf("%s + %s: unexpected error %v", c.base, c.endpoint, err)
+			continue
+		}
+		if resp.Text() != c.path {
+			t.Errorf("%s + %s: expected path '%s', got '%s'", c.base, c.endpoint, c.path, resp.Text())
+		}
+	}
+
+	for _, endpoint := range []string{"ftp://example.com/file", "localhost:8080/x"} {
+		if _, err := fetch.Get(endpoint).Do(); !errors.Is(err, fetch.ErrBuild) {
+			t.Errorf("%s: expected ErrBuild for unsupported scheme, got %v", endpoint, err)
+		}
+	}
+	if _, err := fetch.NewClient().SetBaseURL("example.com").Get("/x").Do(); !errors.Is(err, fetch.ErrBuild) {
+		t.Errorf("Expected ErrBuild for relative base URL, got %v", err)
+	}
+}
+
+func SendRequest_PathParamsShared(t *testing.T, baseURL string) {
+	api := fetch.NewClient().SetBaseURL(baseURL)
+
+	resp, err := api.Get("/path/users/{id}/posts/{postID}").
+		Param("id", "123").
+		Param("postID", "a b/c").
+		Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.Text() != "/path/users/123/posts/a%20b%2Fc" {
+		t.Errorf("Expected escaped segments, got '%s'", resp.Text())
+	}
+
+	// Params from the struct itself, overridable per request.
+	post := MockPost{UserID: "7", ID: "42"}
+	resp, err = api.Get(post).Do()
+	if err != nil || resp.Text() != "/path/users/7/posts/42" {
+		t.Errorf("Expected provider params, got %v / %v", resp, err)
+	}
+	resp, err = api.Get(post).Param("postID", "a/b").Do()
+	if err != nil || resp.Text() != "/path/users/7/posts/a%2Fb" {
+		t.Errorf("Expected overridden and escaped param, got %v / %v", resp, err)
+	}
+
+	// Dot segments would climb the path even escaped, so they are refused.
+	_, err = api.Get(post).Param("postID", "..").Do()
+	if !errors.Is(err, fetch.ErrBuild) || !strings.Contains(err.Error(), "postID") {
+		t.Errorf("Expected ErrBuild for a dot segment param, got %v", err)
+	}
+
+	_, err = api.Get("/path/users/{id}").Do()
+	if !errors.Is(err, fetch.ErrBuild) || !strings.Contains(err.Error(), "id") {
+		t.Errorf("Expected ErrBuild naming the missing param, got %v", err)
+	}
+
+	// An empty value counts as missing rather than dropping the segment.
+	_, err = api.Delete("/path/users/{id}").Param("id", "").Do()
+	if !errors.Is(err, fetch.ErrBuild) || !strings.Contains(err.Error(), "id") {
+		t.Errorf("Expected ErrBuild for an empty param, got %v", err)
+	}
+
+	// Braces after the path are not placeholders.
+	resp, err = api.Get(`/query?filter={"a":1}&tpl={id}`).Do()
+	if err != nil {
+		t.Fatalf("Expected braces in the query to pass through, got %v", err)
+	}
+	// Browsers percent-encode the quotes of a query.
+	if got := strings.ReplaceAll(resp.Text(), "%22", `"`); got != `filter={"a":1}&tpl={id}` {
+		t.Errorf("Expected the query unchanged, got '%s'", resp.Text())
+	}
+}
+
+func SendRequest_CodecShared(t *testing.T, baseURL string) {
+	type message struct {
+		Message string `json:"message"`
+		Count   int    `json:"count"`
+	}
+
+	resp, err := fetch.Post(baseURL+"/post_json").
+		Encode(jsoncodec.JSON, message{Message: "hello", Count: 2}).
+		Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var got message
+	if err := resp.Decode(jsoncodec.JSON, &got); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if got.Message != "hello" || got.Count != 2 {
+		t.Errorf("Unexpected decoded value: %+v", got)
+	}
+
+	// A streamed body is read to the end before decoding.
+	resp, err = fetch.Post(baseURL+"/post_json").
+		Encode(jsoncodec.JSON, message{Message: "streamed", Count: 3}).
+		Stream().
+		Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	got = message{}
+	if err := resp.Decode(jsoncodec.JSON, &got); err != nil || got.Message != "streamed" || got.Count != 3 {
+		t.Errorf("Expected the streamed body to be decoded, got %+v / %v", got, err)
+	}
+
+	// Encoding errors surface as build errors when sending.
+	_, err = fetch.Post(baseURL+"/post_json").Encode(jsoncodec.JSON, make(chan int)).Do()
+	if !errors.Is(err, fetch.ErrBuild) {
+		t.Errorf("Expected ErrBuild for unencodable value, got %v", err)
+	}
+
+	resp, _ = fetch.Get(baseURL + "/get").Do()
+	if err := resp.Decode(jsoncodec.JSON, &got); err == nil {
+		t.Error("Expected decode error for non-JSON body")
+	}
+}
+
+func SendRequest_MultipartShared(t *testing.T, baseURL string) {
+	form := fetch.NewMultipart().
+		Field("title", "My \"report\"").
+		Field("tag", "a").
+		Field("tag", "b").
+		File("file", "report.csv", "text/csv", []byte("id,name\n1,Alice"))
+
+	resp, err := fetch.Post(baseURL + "/multipart").Multipart(form).Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := `title=My "report";tag=a;tag=b;file=report.csv:text/csv:id,name` + "\n1,Alice;"
+	if resp.Status != 200 || resp.Text() != want {
+		t.Errorf("Expected '%s', got %d '%s'", want, resp.Status, resp.Text())
+	}
+}
+
+func SendRequest_FormShared(t *testing.T, baseURL string) {
+	form := fetch.Values{}.
+		Add("a", "1").
+		Add("b", "two words").
+		Add("b", "x&y=z")
+
+	resp, err := fetch.Post(baseURL + "/form").Form(form).Do()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := "application/x-www-form-urlencoded|a=1&b=two%20words&b=x%26y%3Dz|two words,x&y=z"
+	if resp.Text() != want {
+		t.Errorf("Expected '%s', got '%s'", want, resp.Text())
+	}
+}
+
+func SendRequest_BodyReaderShared(t *testing.T, baseURL string) {
+	content := "streamed body content"
+
+	t.Run("KnownSize", func(t *testing.T) {
+		resp, err := fetch.Post(baseURL+"/upload").
+			ContentTypeBinary().
+			BodyReader(strings.NewReader(content), int64(len(content))).
+			Do()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if resp.Text() != content {
+			t.Errorf("Expected echoed body '%s', got '%s'", content, resp.Text())
+		}
+	})
+
+	t.Run("UnknownSize", func(t *testing.T) {
+		// MultiReader hides the concrete reader type, as a file or pipe would.
+		resp, err := fetch.Post(baseURL+"/upload").
+			ContentTypeBinary().
+			BodyReader(io.MultiReader(strings.NewReader(content)), -1).
+			Do()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if resp.Text() != content {
+			t.Errorf("Expected echoed body '%s', got '%s'", content, resp.Text())
+		}
+	})
+
+	t.Run("NotRetried", func(t *testing.T) {
+		id := uniqueID()
+		_, err := fetch.Post(baseURL+"/flaky?id="+id+"&fail=1").
+			BodyReader(strings.NewReader(content), int64(len(content))).
+			Retry(fetch.RetryPolicy{MaxAttempts: 3, BaseDelay: 10, AllowNonIdempotent: true}).
+			FailOnHTTPError().
+			Do()
+		var httpErr *fetch.HTTPError
+		if !errors.As(err, &httpErr) {
+			t.Errorf("Expected the failed attempt to be reported without retrying, got %v", err)
+		}
+	})
+}
+
+func SendRequest_StreamShared(t *testing.T, baseURL string) {
+	t.Run("Lines", func(t *testing.T) {
+		// The first line is flushed at once, the rest 200ms apart.
+		start := time.Now()
+		resp, err := fetch.Get(baseURL + "/stream?lines=3&delay=200").Stream().Do()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 350*time.Millisecond {
+			t.Errorf("Expected the response before the whole body, got it after %v", elapsed)
+		}
+		if len(resp.Body()) != 0 {
+			t.Errorf("Expected no buffered body, got '%s'", resp.Text())
+		}
+
+		body := resp.BodyReader()
+		defer body.Close()
+		var lines []string
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("Expected no read error, got %v", err)
+		}
+		want := []string{`{"n":1}`, `{"n":2}`, `{"n":3}`}
+		if strings.Join(lines, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected lines %v, got %v", want, lines)
+		}
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		resp, err := fetch.Get(baseURL + "/stream?lines=3&delay=1000").Context(ctx).Stream().Do()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		body := resp.BodyReader()
+		defer body.Close()
+
+		reader := bufio.NewReader(body)
+		if line, err := reader.ReadString('\n'); err != nil || line != "{\"n\":1}\n" {
+			t.Fatalf("Expected the first line, got %q / %v", line, err)
+		}
+		cancel()
+		_, err = reader.ReadString('\n')
+		if !errors.Is(err, fetch.ErrAborted) {
+			t.Errorf("Expected ErrAborted while reading, got %v", err)
+		}
+	})
+
+	t.Run("FailOnHTTPError", func(t *testing.T) {
+		_, err := fetch.Get(baseURL + "/error").Stream().FailOnHTTPError().Do()
+		var httpErr *fetch.HTTPError
+		if !errors.As(err, &httpErr) {
+			t.Fatalf("Expected *HTTPError, got %v", err)
+		}
+		if !strings.Contains(httpErr.Response.Text(), "internal server error") {
+			t.Errorf("Expected the buffered error payload, got '%s'", httpErr.Response.Text())
+		}
+	})
+}
+
+func SendRequest_ProgressShared(t *testing.T, baseURL string) {
+	t.Run("Upload", func(t *testing.T) {
+		data := []byte(strings.Repeat("u", 64*1024))
+		var calls int
+		var sent, total int64
+		_, err := fetch.Post(baseURL + "/upload").
+			ContentTypeBinary().
+			Body(data).
+			OnUploadProgress(func(n, size int64) {
+				calls++
+				sent, total = n, size
+			}).
+			Do()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if calls == 0 || sent != int64(len(data)) || total != int64(len(data)) {
+			t.Errorf("Expected final progress %d/%d, got %d/%d after %d calls", len(data), len(data), sent, total, calls)
+		}
+	})
+
+	t.Run("Download", func(t *testing.T) {
+		// The echoed body is small enough to be sent with a Content-Length.
+		data := []byte(strings.Repeat("d", 1000))
+		var received, total int64
+		resp, err := fetch.Post(baseURL + "/upload").
+			ContentTypeBinary().
+			Body(data).
+			OnDownloadProgress(func(n, size int64) {
+				received, total = n, size
+			}).
+			Do()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(resp.Body()) != len(data) {
+			t.Errorf("Expected a %d bytes body, got %d", len(data), len(resp.Body()))
+		}
+		if received != int64(len(data)) || total != int64(len(data)) {
+			t.Errorf("Expected final progress %d/%d, got %d/%d", len(data), len(data), received, total)
+		}
+	})
+
+	t.Run("UnknownTotal", func(t *testing.T) {
+		var received, total int64
+		resp, err := fetch.Get(baseURL + "/stream?lines=2").
+			Stream().
+			OnDownloadProgress(func(n, size int64) {
+				received, total = n, size
+			}).
+			Do()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		body := resp.BodyReader()
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			t.Fatalf("Expected no read error, got %v", err)
+		}
+		if received != int64(len(data)) || total != -1 {
+			t.Errorf("Expected final progress %d/-1, got %d/%d", len(data), received, total)
+		}
+	})
+}
+
+func SendRequest_MaxBodySizeShared(t *testing.T, baseURL string) {
+	data := []byte(strings.Repeat("m", 1000))
+
+	t.Run("ContentLength", func(t *testing.T) {
+		_, err := fetch.Post(baseURL + "/upload").Body(data).MaxBodySize(100).Do()
+		if !errors.Is(err, fetch.ErrBodyTooLarge) {
+			t.Errorf("Expected ErrBodyTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("WithinLimit", func(t *testing.T) {
+		resp, err := fetch.Post(baseURL + "/upload").Body(data).MaxBodySize(1000).Do()
+		if err != nil || len(resp.Body()) != len(data) {
+			t.Errorf("Expected the body within the limit, got %v", err)
+		}
+	})
+
+	t.Run("UnknownLength", func(t *testing.T) {
+		// The streamed lines are sent chunked, without Content-Length.
+		_, err := fetch.Get(baseURL + "/stream?lines=50").MaxBodySize(100).Do()
+		if !errors.Is(err, fetch.ErrBodyTooLarge) {
+			t.Errorf("Expected ErrBodyTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("ClientDefault", func(t *testing.T) {
+		api := fetch.NewClient().SetBaseURL(baseURL).SetMaxBodySize(100)
+		_, err := api.Get("/stream?lines=50").Do()
+		if !errors.Is(err, fetch.ErrBodyTooLarge) {
+			t.Errorf("Expected ErrBodyTooLarge from the client limit, got %v", err)
+		}
+		if _, err := api.Get("/stream?lines=50").MaxBodySize(1000).Do(); err != nil {
+			t.Errorf("Expected the request limit to override the client one, got %v", err)
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		resp, err := fetch.Get(baseURL + "/stream?lines=50").Stream().MaxBodySize(100).Do()
+		if err != nil {
+			t.Fatalf("Expected no error before reading, got %v", err)
+		}
+		body := resp.BodyReader()
+		defer body.Close()
+		read, err := io.ReadAll(body)
+		if !errors.Is(err, fetch.ErrBodyTooLarge) {
+			t.Errorf("Expected ErrBodyTooLarge while reading, got %v", err)
+		}
+		if len(read) > 100 {
+			t.Errorf("Expected at most 100 bytes before failing, got %d", len(read))
+		}
+	})
+}
+
+func SendRequest_CacheShared(t *testing.T, baseURL string) {
+	// get sends a GET through api with the given header pairs, failing on errors.
+	get := func(t *testing.T, api *fetch.Client, endpoint string, headers ...string) *fetch.Response {
+		t.Helper()
+		req := api.Get(endpoint)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header(headers[i], headers[i+1])
+		}
+		resp, err := req.Do()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+	newClient := func() *fetch.Client {
+		return fetch.NewClient().SetBaseURL(baseURL).SetCache(fetch.NewCache(nil))
+	}
+
+	t.Run("Fresh", func(t *testing.T) {
+		api := newClient()
+		endpoint := "/cached?id=" + uniqueID() + "&cache_control=max-age%3D60"
+		get(t, api, endpoint)
+		if resp := get(t, api, endpoint); resp.Text() != "calls=1" || resp.Status != 200 {
+			t.Errorf("Expected the fresh response from the cache, got %d '%s'", resp.Status, resp.Text())
+		}
+	})
+
+	t.Run("NoStore", func(t *testing.T) {
+		api := newClient()
+		endpoint := "/cached?id=" + uniqueID() + "&cache_control=no-store"
+		get(t, api, endpoint)
+		if resp := get(t, api, endpoint); resp.Text() != "calls=2" {
+			t.Errorf("Expected no-store responses to be fetched again, got '%s'", resp.Text())
+		}
+	})
+
+	t.Run("Revalidate", func(t *testing.T) {
+		api := newClient()
+		endpoint := "/cached?id=" + uniqueID() + "&cache_control=no-cache&etag=v1"
+		get(t, api, endpoint)
+		resp := get(t, api, endpoint)
+		if resp.Status != 200 || resp.Text() != "calls=1" {
+			t.Errorf("Expected the 304 to turn into the cached response, got %d '%s'", resp.Status, resp.Text())
+		}
+		if resp.GetHeader("X-Calls") != "2" {
+			t.Errorf("Expected the headers of the 304 to update the entry, got X-Calls %q", resp.GetHeader("X-Calls"))
+		}
+	})
+
+	t.Run("Vary", func(t *testing.T) {
+		api := newClient()
+		endpoint := "/cached?id=" + uniqueID() + "&cache_control=max-age%3D60&vary=X-Custom"
+		get(t, api, endpoint, "X-Custom", "a")
+		if resp := get(t, api, endpoint, "X-Custom", "b"); resp.Text() != "calls=2 X-Custom=b" {
+			t.Errorf("Expected a different Vary header to miss the cache, got '%s'", resp.Text())
+		}
+		if resp := get(t, api, endpoint, "X-Custom", "b"); resp.Text() != "calls=2 X-Custom=b" {
+			t.Errorf("Expected the same Vary header to hit the cache, got '%s'", resp.Text())
+		}
+	})
+
+	t.Run("RequestNoCache", func(t *testing.T) {
+		api := newClient()
+		endpoint := "/cached?id=" + uniqueID() + "&cache_control=max-age%3D60"
+		get(t, api, endpoint)
+		if resp := get(t, api, endpoint, "Cache-Control", "no-cache"); resp.Text() != "calls=2" {
+			t.Errorf("Expected a no-cache request to reach the server, got '%s'", resp.Text())
+		}
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		api := newClient()
+		endpoint := "/cached?id=" + uniqueID() + "&cache_control=max-age%3D60"
+		get(t, api, endpoint)
+		if _, err := api.Post(endpoint).Do(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if resp := get(t, api, endpoint); resp.Text() != "calls=3" {
+			t.Errorf("Expected the POST to invalidate the cached response, got '%s'", resp.Text())
+		}
+	})
+}
+
+func SendRequest_CoalesceShared(t *testing.T, baseURL string) {
+	type result struct {
+		text string
+		err  error
+	}
+	newClient := func() *fetch.Client {
+		return fetch.NewClient().SetBaseURL(baseURL).SetCoalesce(true)
+	}
+	send := func(req *fetch.Request, results chan result) *fetch.Call {
+		return req.Send(func(resp *fetch.Response, err error) {
+			if err != nil {
+				results <- result{err: err}
+				return
+			}
+			results <- result{text: resp.Text()}
+		})
+	}
+	wait := func(t *testing.T, results chan result) result {
+		t.Helper()
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(2 * time.Second):
+			t.Fatal("Callback was not called")
+			return result{}
+		}
+	}
+
+	t.Run("Shared", func(t *testing.T) {
+		api := newClient()
+		endpoint := "/counter?id=" + uniqueID() + "&delay=100"
+		results := make(chan result, 3)
+		for i := 0; i < 3; i++ {
+			send(api.Get(endpoint), results)
+		}
+		for i := 0; i < 3; i++ {
+			if r := wait(t, results); r.err != nil || r.text != "1" {
+				t.Errorf("Expected every request to share the first call, got '%s' %v", r.text, r.err)
+			}
+		}
+		// Completed requests are not reused.
+		send(api.Get(endpoint), results)
+		if r := wait(t, results); r.text != "2" {
+			t.Errorf("Expected a new call once the first completed, got '%s' %v", r.text, r.err)
+		}
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		api := newClient()
+		endpoint := "/counter?id=" + uniqueID() + "&delay=100"
+		aborted := make(chan result, 1)
+		results := make(chan result, 1)
+		call := send(api.Get(endpoint), aborted)
+		send(api.Get(endpoint), results)
+		call.Abort()
+		if r := wait(t, aborted); !errors.Is(r.err, fetch.ErrAborted) {
+			t.Errorf("Expected ErrAborted, got %v", r.err)
+		}
+		if r := wait(t, results); r.err != nil || r.text != "1" {
+			t.Errorf("Expected the other request to complete, got '%s' %v", r.text, r.err)
+		}
+	})
+
+	t.Run("Headers", func(t *testing.T) {
+		api := newClient()
+		endpoint := "/counter?id=" + uniqueID() + "&delay=100"
+		results := make(chan result, 2)
+		send(api.Get(endpoint).Header("X-Custom", "a"), results)
+		send(api.Get(endpoint).Header("X-Custom", "b"), results)
+		texts := wait(t, results).text + wait(t, results).text
+		if texts != "12" && texts != "21" {
+			t.Errorf("Expected requests with different headers to be sent separately, got '%s'", texts)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		api := newClient()
+		endpoint := "/counter?id=" + uniqueID() + "&delay=100"
+		short := make(chan result, 1)
+		long := make(chan result, 1)
+		send(api.Get(endpoint).Timeout(20), short)
+		send(api.Get(endpoint).Timeout(2000), long)
+		if r := wait(t, short); !errors.Is(r.err, fetch.ErrTimeout) {
+			t.Errorf("Expected the short timeout to expire, got '%s' %v", r.text, r.err)
+		}
+		if r := wait(t, long); r.err != nil {
+			t.Errorf("Expected requests with different timeouts to be sent separately, got %v", r.err)
+		}
+	})
+
+	t.Run("Unsafe", func(t *testing.T) {
+		api := newClient()
+		endpoint := "/counter?id=" + uniqueID() + "&delay=100"
+		results := make(chan result, 2)
+		send(api.Post(endpoint), results)
+		send(api.Post(endpoint), results)
+		texts := wait(t, results).text + wait(t, results).text
+		if texts != "12" && texts != "21" {
+			t.Errorf("Expected POST requests to be sent separately, got '%s'", texts)
+		}
+	})
+}
+
+func SendRequest_LimitShared(t *testing.T, baseURL string) {
+	type result struct {
+		name string
+		text string
+		err  error
+	}
+	send := func(req *fetch.Request, name string, results chan result) *fetch.Call {
+		return req.Send(func(resp *fetch.Response, err error) {
+			if err != nil {
+				results <- result{name: name, err: err}
+				return
+			}
+			results <- result{name: name, text: resp.Text()}
+		})
+	}
+	wait := func(t *testing.T, results chan result) result {
+		t.Helper()
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(2 * time.Second):
+			t.Fatal("Callback was not called")
+			return result{}
+		}
+	}
+
+	t.Run("Priority", func(t *testing.T) {
+		api := fetch.NewClient().SetBaseURL(baseURL).SetMaxInFlight(1)
+		endpoint := "/counter?id=" + uniqueID() + "&delay=50"
+		results := make(chan result, 4)
+		send(api.Get(endpoint).Priority(fetch.PriorityLow), "first", results)
+		send(api.Get(endpoint).Priority(fetch.PriorityLow), "low", results)
+		send(api.Get(endpoint), "normal", results)
+		send(api.Get(endpoint).Priority(fetch.PriorityHigh), "high", results)
+
+		var order string
+		for i := 0; i < 4; i++ {
+			r := wait(t, results)
+			if r.err != nil {
+				t.Fatalf("Expected no error, got %v", r.err)
+			}
+			order += r.name + "=" + r.text + " "
+		}
+		if order != "first=1 high=2 normal=3 low=4 " {
+			t.Errorf("Expected the queue to be served one at a time by priority, got '%s'", order)
+		}
+	})
+
+	t.Run("PerHost", func(t *testing.T) {
+		api := fetch.NewClient().SetBaseURL(baseURL).SetMaxPerHost(1)
+		endpoint := "/counter?id=" + uniqueID() + "&delay=100"
+		results := make(chan result, 2)
+		start := time.Now()
+		send(api.Get(endpoint), "a", results)
+		send(api.Get(endpoint), "b", results)
+		wait(t, results)
+		wait(t, results)
+		if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+			t.Errorf("Expected requests to the same host to be sent one at a time, took %v", elapsed)
+		}
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		api := fetch.NewClient().SetBaseURL(baseURL).SetMaxInFlight(1)
+		endpoint := "/counter?id=" + uniqueID() + "&delay=100"
+		results := make(chan result, 3)
+		send(api.Get(endpoint), "first", results)
+		send(api.Get(endpoint), "queued", results).Abort()
+		if r := wait(t, results); r.name != "queued" || !errors.Is(r.err, fetch.ErrAborted) {
+			t.Errorf("Expected the queued request to be aborted first, got %s %v", r.name, r.err)
+		}
+		wait(t, results)
+		send(api.Get(endpoint), "last", results)
+		if r := wait(t, results); r.text != "2" {
+			t.Errorf("Expected the aborted request never to be sent, got '%s' %v", r.text, r.err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		api := fetch.NewClient().SetBaseURL(baseURL).SetMaxInFlight(1)
+		endpoint := "/counter?id=" + uniqueID() + "&delay=300"
+		results := make(chan result, 2)
+		send(api.Get(endpoint), "first", results)
+		send(api.Get(endpoint).Timeout(50), "queued", results)
+		if r := wait(t, results); r.name != "queued" || !errors.Is(r.err, fetch.ErrTimeout) {
+			t.Errorf("Expected the queued request to time out first, got %s %v", r.name, r.err)
+		}
+		wait(t, results)
+	})
+}
+
+func SendRequest_RateLimitShared(t *testing.T, baseURL string) {
+	// sendAll sends the requests at once and returns the time until all
+	// of them completed.
+	sendAll := func(t *testing.T, reqs ...*fetch.Request) time.Duration {
+		t.Helper()
+		errs := make(chan error, len(reqs))
+		start := time.Now()
+		for _, req := range reqs {
+			req.Send(func(resp *fetch.Response, err error) { errs <- err })
+		}
+		for range reqs {
+			select {
+			case err := <-errs:
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("Callback was not called")
+			}
+		}
+		return time.Since(start)
+	}
+
+	t.Run("Bucket", func(t *testing.T) {
+		api := fetch.NewClient().SetBaseURL(baseURL).SetRateLimit("", fetch.RateLimit{Rate: 10, Burst: 2})
+		// The burst is sent at once, then one request every 100ms.
+		elapsed := sendAll(t, api.Get("/get"), api.Get("/get"), api.Get("/get"), api.Get("/get"))
+		if elapsed < 180*time.Millisecond || elapsed > time.Second {
+			t.Errorf("Expected 4 requests at 10/s with a burst of 2 to take about 200ms, took %v", elapsed)
+		}
+	})
+
+	t.Run("BaseURL", func(t *testing.T) {
+		api := fetch.NewClient().SetBaseURL(baseURL).SetRateLimit(baseURL+"/counter", fetch.RateLimit{Rate: 1})
+		if elapsed := sendAll(t, api.Get("/get"), api.Get("/get"), api.Get("/get")); elapsed > 500*time.Millisecond {
+			t.Errorf("Expected requests outside the base URL not to be limited, took %v", elapsed)
+		}
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		api := fetch.NewClient().SetBaseURL(baseURL).SetRateLimit("", fetch.RateLimit{Rate: 1})
+		sendAll(t, api.Get("/get"))
+		errs := make(chan error, 1)
+		start := time.Now()
+		api.Get("/get").Send(func(resp *fetch.Response, err error) { errs <- err }).Abort()
+		if err := <-errs; !errors.Is(err, fetch.ErrAborted) || time.Since(start) > 500*time.Millisecond {
+			t.Errorf("Expected a waiting request to abort at once, got %v after %v", err, time.Since(start))
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		api := fetch.NewClient().SetBaseURL(baseURL).SetRateLimit("", fetch.RateLimit{Rate: 1})
+		sendAll(t, api.Get("/get"))
+		if _, err := api.Get("/get").Timeout(100).Do(); !errors.Is(err, fetch.ErrTimeout) {
+			t.Errorf("Expected a wait longer than the timeout to fail with ErrTimeout, got %v", err)
+		}
+	})
+
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		t.Run("FromHeaders "+prefix, func(t *testing.T) {
+			api := fetch.NewClient().SetBaseURL(baseURL).SetRateLimit("", fetch.RateLimit{FromHeaders: true})
+			endpoint := "/ratelimit?prefix=" + prefix + "&reset=1&remaining="
+			if elapsed := sendAll(t, api.Get(endpoint+"5")); elapsed > 500*time.Millisecond {
+				t.Fatalf("Expected the first request not to wait, took %v", elapsed)
+			}
+			sendAll(t, api.Get(endpoint+"0"))
+			if elapsed := sendAll(t, api.Get("/get")); elapsed < 800*time.Millisecond {
+				t.Errorf("Expected requests to wait for the reported reset, took %v", elapsed)
+			}
+		})
+	}
+}
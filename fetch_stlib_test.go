@@ -3,7 +3,14 @@
 package fetch_test
 
 import (
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/tinywasm/fetch"
 )
 
 func TestStdlib(t *testing.T) {
@@ -20,4 +27,117 @@ func TestStdlib(t *testing.T) {
 	t.Run("Headers", func(t *testing.T) { SendRequest_HeadersShared(t, server.URL) })
 	t.Run("ContentTypes", func(t *testing.T) { SendRequest_ContentTypesShared(t, server.URL) })
 	t.Run("Dispatch", func(t *testing.T) { SendRequest_DispatchShared(t, server.URL) })
+	t.Run("Client", func(t *testing.T) { SendRequest_ClientShared(t, server.URL) })
+	t.Run("Abort", func(t *testing.T) { SendRequest_AbortShared(t, server.URL) })
+	t.Run("Context", func(t *testing.T) { SendRequest_ContextShared(t, server.URL) })
+	t.Run("Do", func(t *testing.T) { SendRequest_DoShared(t, server.URL) })
+	t.Run("ErrorKinds", func(t *testing.T) { SendRequest_ErrorKindsShared(t, server.URL) })
+	t.Run("FailOnHTTPError", func(t *testing.T) { SendRequest_FailOnHTTPErrorShared(t, server.URL) })
+	t.Run("Retry", func(t *testing.T) { SendRequest_RetryShared(t, server.URL) })
+	t.Run("Middleware", func(t *testing.T) { SendRequest_MiddlewareShared(t, server.URL) })
+	t.Run("Transport", func(t *testing.T) { SendRequest_TransportShared(t, server.URL) })
+	t.Run("Methods", func(t *testing.T) { SendRequest_MethodsShared(t, server.URL) })
+	t.Run("Query", func(t *testing.T) { SendRequest_QueryShared(t, server.URL) })
+	t.Run("URLResolution", func(t *testing.T) { SendRequest_URLResolutionShared(t, server.URL) })
+	t.Run("PathParams", func(t *testing.T) { SendRequest_PathParamsShared(t, server.URL) })
+	t.Run("Codec", func(t *testing.T) { SendRequest_CodecShared(t, server.URL) })
+	t.Run("Multipart", func(t *testing.T) { SendRequest_MultipartShared(t, server.URL) })
+	t.Run("Form", func(t *testing.T) { SendRequest_FormShared(t, server.URL) })
+	t.Run("BodyReader", func(t *testing.T) { SendRequest_BodyReaderShared(t, server.URL) })
+	t.Run("Stream", func(t *testing.T) { SendRequest_StreamShared(t, server.URL) })
+	t.Run("Progress", func(t *testing.T) { SendRequest_ProgressShared(t, server.URL) })
+	t.Run("MaxBodySize", func(t *testing.T) { SendRequest_MaxBodySizeShared(t, server.URL) })
+	t.Run("Cache", func(t *testing.T) { SendRequest_CacheShared(t, server.URL) })
+	t.Run("Coalesce", func(t *testing.T) { SendRequest_CoalesceShared(t, server.URL) })
+	t.Run("Limit", func(t *testing.T) { SendRequest_LimitShared(t, server.URL) })
+	t.Run("RateLimit", func(t *testing.T) { SendRequest_RateLimitShared(t, server.URL) })
+}
+
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestStdlibTransport(t *testing.T) {
+	t.Run("RoundTripper", func(t *testing.T) {
+		rt := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"X-Seen": {req.Header.Get("X-Custom")}},
+				Body:       io.NopCloser(strings.NewReader("stubbed " + req.URL.Path)),
+			}, nil
+		})
+		api := fetch.NewClient().SetTransport(fetch.NewRoundTripperTransport(rt))
+
+		resp, err := api.Get("http://stub.invalid/path").Header("X-Custom", "v").Do()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if resp.Text() != "stubbed /path" || resp.GetHeader("X-Seen") != "v" {
+			t.Errorf("Unexpected stubbed response: %s %v", resp.Text(), resp.Headers)
+		}
+	})
+
+	t.Run("HTTPClient", func(t *testing.T) {
+		server := setupTestServer()
+		defer server.Close()
+
+		api := fetch.NewClient().
+			SetBaseURL(server.URL).
+			SetTransport(fetch.NewHTTPTransport(server.Client()))
+		resp, err := api.Get("/get").Do()
+		if err != nil || resp.Text() != "get success" {
+			t.Errorf("Expected response through custom http.Client, got %v / %v", resp, err)
+		}
+	})
+}
+
+func TestStdlibBodyReader(t *testing.T) {
+	// contentLength reports the length the transport sent for a BodyReader.
+	contentLength := func(size int64) int64 {
+		var length int64
+		rt := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			length = req.ContentLength
+			io.Copy(io.Discard, req.Body)
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+		api := fetch.NewClient().SetTransport(fetch.NewRoundTripperTransport(rt))
+		if _, err := api.Post("http://stub.invalid/upload").BodyReader(strings.NewReader("12345"), size).Do(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return length
+	}
+
+	if length := contentLength(5); length != 5 {
+		t.Errorf("Expected Content-Length 5, got %d", length)
+	}
+	if length := contentLength(-1); length != -1 {
+		t.Errorf("Expected unknown length (chunked), got %d", length)
+	}
+}
+
+func TestStdlibUploadProgressReleasesBody(t *testing.T) {
+	// A failed send must close the multipart pipe behind the progress
+	// wrapper, or its writer goroutine blocks forever.
+	before := runtime.NumGoroutine()
+	form := fetch.NewMultipart().File("file", "data.bin", "", make([]byte, 64<<10))
+	for i := 0; i < 20; i++ {
+		_, err := fetch.Post("http://127.0.0.1:1/upload").
+			Multipart(form).
+			OnUploadProgress(func(sent, total int64) {}).
+			Do()
+		if err == nil {
+			t.Fatal("Expected an unreachable host to fail")
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before+5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before+5 {
+		t.Errorf("Expected the request bodies to be released, %d goroutines left running", n-before)
+	}
 }
@@ -5,7 +5,11 @@ package fetch_test
 import (
 	"net/http"
 	"os"
+	"strings"
+	"syscall/js"
 	"testing"
+
+	"github.com/tinywasm/fetch"
 )
 
 func TestWasm(t *testing.T) {
@@ -33,4 +37,81 @@ func TestWasm(t *testing.T) {
 	t.Run("Headers", func(t *testing.T) { SendRequest_HeadersShared(t, serverURL) })
 	t.Run("ContentTypes", func(t *testing.T) { SendRequest_ContentTypesShared(t, serverURL) })
 	t.Run("Dispatch", func(t *testing.T) { SendRequest_DispatchShared(t, serverURL) })
+	t.Run("Client", func(t *testing.T) { SendRequest_ClientShared(t, serverURL) })
+	t.Run("Abort", func(t *testing.T) { SendRequest_AbortShared(t, serverURL) })
+	t.Run("Context", func(t *testing.T) { SendRequest_ContextShared(t, serverURL) })
+	t.Run("Do", func(t *testing.T) { SendRequest_DoShared(t, serverURL) })
+	t.Run("ErrorKinds", func(t *testing.T) { SendRequest_ErrorKindsShared(t, serverURL) })
+	t.Run("FailOnHTTPError", func(t *testing.T) { SendRequest_FailOnHTTPErrorShared(t, serverURL) })
+	t.Run("Retry", func(t *testing.T) { SendRequest_RetryShared(t, serverURL) })
+	t.Run("Middleware", func(t *testing.T) { SendRequest_MiddlewareShared(t, serverURL) })
+	t.Run("Transport", func(t *testing.T) { SendRequest_TransportShared(t, serverURL) })
+	t.Run("Methods", func(t *testing.T) { SendRequest_MethodsShared(t, serverURL) })
+	t.Run("Query", func(t *testing.T) { SendRequest_QueryShared(t, serverURL) })
+	t.Run("URLResolution", func(t *testing.T) { SendRequest_URLResolutionShared(t, serverURL) })
+	t.Run("PathParams", func(t *testing.T) { SendRequest_PathParamsShared(t, serverURL) })
+	t.Run("Codec", func(t *testing.T) { SendRequest_CodecShared(t, serverURL) })
+	t.Run("Multipart", func(t *testing.T) { SendRequest_MultipartShared(t, serverURL) })
+	t.Run("Form", func(t *testing.T) { SendRequest_FormShared(t, serverURL) })
+	t.Run("BodyReader", func(t *testing.T) { SendRequest_BodyReaderShared(t, serverURL) })
+	t.Run("Stream", func(t *testing.T) { SendRequest_StreamShared(t, serverURL) })
+	t.Run("Progress", func(t *testing.T) { SendRequest_ProgressShared(t, serverURL) })
+	t.Run("MaxBodySize", func(t *testing.T) { SendRequest_MaxBodySizeShared(t, serverURL) })
+	t.Run("Cache", func(t *testing.T) { SendRequest_CacheShared(t, serverURL) })
+	t.Run("Coalesce", func(t *testing.T) { SendRequest_CoalesceShared(t, serverURL) })
+	t.Run("Limit", func(t *testing.T) { SendRequest_LimitShared(t, serverURL) })
+	t.Run("RateLimit", func(t *testing.T) { SendRequest_RateLimitShared(t, serverURL) })
+}
+
+func TestBrowserCacheStore(t *testing.T) {
+	if js.Global().Get("caches").IsUndefined() {
+		t.Skip("Cache Storage is unavailable in this context")
+	}
+	name := "fetch-test-" + uniqueID()
+	defer js.Global().Get("caches").Call("delete", name)
+
+	entry := func(body string) *fetch.CacheEntry {
+		return &fetch.CacheEntry{
+			URL:          "https://example.com/data",
+			Status:       200,
+			Headers:      []fetch.Header{{Key: "ETag", Value: `"v1"`}},
+			Body:         []byte(body),
+			Vary:         []fetch.Header{{Key: "Accept", Value: "text/plain"}},
+			RequestTime:  1,
+			ResponseTime: 2,
+		}
+	}
+
+	store := fetch.NewBrowserCacheStore(name, 1000)
+	store.Store("GET https://example.com/a", entry("first"))
+
+	// A new store over the same cache finds the persisted entry.
+	store = fetch.NewBrowserCacheStore(name, 1000)
+	got, ok := store.Load("GET https://example.com/a")
+	if !ok {
+		t.Fatal("Expected the entry to persist")
+	}
+	if string(got.Body) != "first" || got.Status != 200 || got.Headers[0].Value != `"v1"` ||
+		got.Vary[0].Value != "text/plain" || got.ResponseTime != 2 {
+		t.Errorf("Unexpected entry after a round trip: %+v", got)
+	}
+
+	// Storing past the quota evicts the least recently used entries,
+	// including the one indexed when the store was opened.
+	store.Store("GET https://example.com/b", entry(strings.Repeat("b", 600)))
+	store.Store("GET https://example.com/c", entry(strings.Repeat("c", 600)))
+	if _, ok := store.Load("GET https://example.com/b"); ok {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	if _, ok := store.Load("GET https://example.com/a"); ok {
+		t.Error("Expected the persisted entry to be evicted")
+	}
+	if _, ok := store.Load("GET https://example.com/c"); !ok {
+		t.Error("Expected the newest entry to be kept")
+	}
+
+	store.Delete("GET https://example.com/c")
+	if _, ok := store.Load("GET https://example.com/c"); ok {
+		t.Error("Expected the deleted entry to be gone")
+	}
 }
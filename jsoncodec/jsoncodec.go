@@ -0,0 +1,39 @@
+// Package jsoncodec provides a fetch.Codec for JSON bodies.
+//
+// It is built on encoding/json, which TinyGo supports, and lives in its own
+// package so the core fetch package stays free of encoding dependencies.
+//
+//	fetch.Post("/users").
+//		Encode(jsoncodec.JSON, user).
+//		Send(func(resp *fetch.Response, err error) {
+//			var created User
+//			err = resp.Decode(jsoncodec.JSON, &created)
+//		})
+package jsoncodec
+
+import (
+	"encoding/json"
+
+	"github.com/tinywasm/fetch"
+)
+
+// Codec encodes and decodes JSON. The zero value is ready to use.
+type Codec struct{}
+
+// JSON is a ready to use JSON codec.
+var JSON fetch.Codec = Codec{}
+
+// Encode returns the JSON encoding of v.
+func (Codec) Encode(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Decode parses the JSON data into v.
+func (Codec) Decode(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// ContentType returns "application/json".
+func (Codec) ContentType() string {
+	return "application/json"
+}
@@ -0,0 +1,76 @@
+package fetch
+
+import (
+	"io"
+
+	. "github.com/tinywasm/fmt"
+)
+
+// MaxBodySize limits the response body to n bytes, overriding the client
+// limit. Larger bodies fail with ErrBodyTooLarge: up front when the
+// Content-Length announces it, otherwise as soon as the read exceeds it.
+func (r *Request) MaxBodySize(n int64) *Request {
+	r.maxBodySize = n
+	return r
+}
+
+// SetMaxBodySize sets the default response body limit of the client in
+// bytes. See Request.MaxBodySize.
+func (c *Client) SetMaxBodySize(n int64) *Client {
+	c.maxBodySize = n
+	return c
+}
+
+// GetMaxBodySize returns the response body limit in bytes, falling back to
+// the client one. Zero means no limit.
+func (r *Request) GetMaxBodySize() int64 {
+	if r.maxBodySize > 0 {
+		return r.maxBodySize
+	}
+	return r.client.maxBodySize
+}
+
+// checkBodySize fails when a body of size bytes exceeds the limit.
+// A negative size is unknown and passes.
+func (r *Request) checkBodySize(url string, size int64) error {
+	if max := r.GetMaxBodySize(); max > 0 && size > max {
+		return r.bodyTooLarge(url)
+	}
+	return nil
+}
+
+func (r *Request) bodyTooLarge(url string) error {
+	limit := Convert(r.GetMaxBodySize()).String()
+	return newError(KindBodyTooLarge, r, url, Err("body exceeds limit of", limit, "bytes"))
+}
+
+// limitBody wraps body to fail reads once they exceed the limit.
+func (r *Request) limitBody(body io.ReadCloser, url string) io.ReadCloser {
+	max := r.GetMaxBodySize()
+	if max <= 0 {
+		return body
+	}
+	return &limitedBody{ReadCloser: body, left: max, tooLarge: func() error { return r.bodyTooLarge(url) }}
+}
+
+// limitedBody reads at most one byte past its limit to detect an overflow.
+type limitedBody struct {
+	io.ReadCloser
+	left     int64
+	tooLarge func() error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left < 0 {
+		return 0, b.tooLarge()
+	}
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	if b.left < 0 {
+		return n + int(b.left), b.tooLarge()
+	}
+	return n, err
+}
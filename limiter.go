@@ -0,0 +1,231 @@
+package fetch
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Priority orders queued requests of a client with SetMaxInFlight or
+// SetMaxPerHost. The zero value is PriorityNormal.
+type Priority int
+
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityHigh
+)
+
+// Priority sets the queue priority of the request. When the client limits
+// the requests in flight, queued requests with a higher priority are sent
+// first; those with the same priority in the order they were sent.
+func (r *Request) Priority(p Priority) *Request {
+	r.priority = p
+	return r
+}
+
+// GetPriority returns the queue priority of the request.
+func (r *Request) GetPriority() Priority {
+	return r.priority
+}
+
+// SetMaxInFlight limits the requests in flight of the default client.
+// See Client.SetMaxInFlight.
+func SetMaxInFlight(n int) {
+	defaultClient.SetMaxInFlight(n)
+}
+
+// SetMaxPerHost limits the requests in flight per host of the default
+// client. See Client.SetMaxPerHost.
+func SetMaxPerHost(n int) {
+	defaultClient.SetMaxPerHost(n)
+}
+
+// SetMaxInFlight limits the requests of the client sent at the same time
+// to n; 0 means no limit. Further requests wait in a queue ordered by
+// Priority. Every attempt of a retried request takes a slot, released
+// while waiting between attempts; a streamed response keeps its slot until
+// its body is closed.
+//
+// A queued request still honours its abort handle, context and timeout:
+// the time spent queued counts towards the timeout.
+func (c *Client) SetMaxInFlight(n int) *Client {
+	c.limits().set(func(l *limiter) { l.maxInFlight = n })
+	return c
+}
+
+// SetMaxPerHost limits the requests of the client sent at the same time to
+// each origin (scheme, host and port) to n; 0 means no limit. It works
+// like SetMaxInFlight, and both limits may be combined.
+func (c *Client) SetMaxPerHost(n int) *Client {
+	c.limits().set(func(l *limiter) { l.maxPerHost = n })
+	return c
+}
+
+// limits returns the limiter of the client, creating it on first use.
+func (c *Client) limits() *limiter {
+	if c.limiter == nil {
+		c.limiter = &limiter{perHost: make(map[string]int)}
+	}
+	return c.limiter
+}
+
+// limiter holds the in-flight counters and queue of a client.
+type limiter struct {
+	mu          sync.Mutex
+	maxInFlight int
+	maxPerHost  int
+	inFlight    int
+	perHost     map[string]int
+	queue       []*queued // by descending priority, then arrival
+}
+
+// queued is a request waiting for a slot.
+type queued struct {
+	r     *Request
+	host  string
+	start func() // called once a slot is taken
+}
+
+// set changes the limits and starts the requests they now allow.
+func (l *limiter) set(fn func(*limiter)) {
+	l.mu.Lock()
+	fn(l)
+	next := l.next()
+	l.mu.Unlock()
+	for _, q := range next {
+		q.start()
+	}
+}
+
+// do sends r through t once a slot is free.
+func (l *limiter) do(r *Request, t Transport, callback func(*Response, error)) {
+	host := urlOrigin(r.url)
+	send := func(r *Request) {
+		var once sync.Once
+		release := func() { once.Do(func() { l.release(host) }) }
+		t.RoundTrip(r, func(resp *Response, err error) {
+			if resp != nil && resp.stream != nil {
+				resp.stream = &releasingBody{ReadCloser: resp.stream, release: release}
+			} else {
+				release()
+			}
+			callback(resp, err)
+		})
+	}
+
+	l.mu.Lock()
+	if l.allows(host) {
+		l.take(host)
+		l.mu.Unlock()
+		send(r)
+		return
+	}
+
+	// Wait in the queue until a slot is free, the request is aborted or
+	// its timeout expires.
+	q := &queued{r: r, host: host}
+	queuedAt := time.Now()
+	timeout := r.GetTimeout()
+	// Both watchers take l.mu in remove, so they see the stop functions.
+	var stopWatch func() bool
+	stopTimer := func() {}
+	if timeout > 0 {
+		stopTimer = afterFunc(timeout, func() {
+			if l.remove(q) {
+				stopWatch()
+				callback(nil, newError(KindTimeout, r, r.url, context.DeadlineExceeded))
+			}
+		})
+	}
+	stopWatch = context.AfterFunc(r.ctx, func() {
+		if l.remove(q) {
+			stopTimer()
+			err := r.ctx.Err()
+			callback(nil, newError(contextErrorKind(err), r, r.url, err))
+		}
+	})
+	q.start = func() {
+		stopTimer()
+		stopWatch()
+		if timeout <= 0 {
+			send(r)
+			return
+		}
+		// The transport gets what is left of the timeout.
+		req := *r
+		req.timeout = max(timeout-int(time.Since(queuedAt).Milliseconds()), 1)
+		send(&req)
+	}
+	l.enqueue(q)
+	l.mu.Unlock()
+}
+
+// allows reports whether a request to host may be sent now.
+func (l *limiter) allows(host string) bool {
+	return (l.maxInFlight <= 0 || l.inFlight < l.maxInFlight) &&
+		(l.maxPerHost <= 0 || l.perHost[host] < l.maxPerHost)
+}
+
+// take counts a request to host as in flight.
+func (l *limiter) take(host string) {
+	l.inFlight++
+	l.perHost[host]++
+}
+
+// enqueue inserts q after the queued requests of the same or higher priority.
+func (l *limiter) enqueue(q *queued) {
+	i := len(l.queue)
+	for i > 0 && l.queue[i-1].r.priority < q.r.priority {
+		i--
+	}
+	l.queue = append(l.queue, nil)
+	copy(l.queue[i+1:], l.queue[i:])
+	l.queue[i] = q
+}
+
+// remove takes q out of the queue. It reports false when q was already
+// started or removed.
+func (l *limiter) remove(q *queued) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, other := range l.queue {
+		if other == q {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// release frees the slot of a request to host and starts the queued
+// requests it allows.
+func (l *limiter) release(host string) {
+	l.mu.Lock()
+	l.inFlight--
+	if l.perHost[host]--; l.perHost[host] <= 0 {
+		delete(l.perHost, host)
+	}
+	next := l.next()
+	l.mu.Unlock()
+	for _, q := range next {
+		q.start()
+	}
+}
+
+// next dequeues the requests that may be sent now, taking their slots.
+// A request waiting for a busy host does not hold back the others.
+func (l *limiter) next() []*queued {
+	var next []*queued
+	for i := 0; i < len(l.queue); {
+		q := l.queue[i]
+		if !l.allows(q.host) {
+			i++
+			continue
+		}
+		l.take(q.host)
+		next = append(next, q)
+		l.queue = append(l.queue[:i], l.queue[i+1:]...)
+	}
+	return next
+}
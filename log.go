@@ -1,23 +1,18 @@
 package fetch
 
-var (
-	logger        func(...any)
-	globalHandler func(*Response)
-)
-
-// SetLog sets the logger function for debugging.
+// SetLog sets the logger function of the default client.
 func SetLog(fn func(...any)) {
-	logger = fn
+	defaultClient.SetLog(fn)
 }
 
-// SetHandler sets the global handler for Dispatch requests.
+// SetHandler sets the handler for Dispatch requests of the default client.
 func SetHandler(fn func(*Response)) {
-	globalHandler = fn
+	defaultClient.SetHandler(fn)
 }
 
 // log prints a message if a logger is set.
-func log(args ...any) {
-	if logger != nil {
-		logger(args...)
+func (c *Client) log(args ...any) {
+	if c.logger != nil {
+		c.logger(args...)
 	}
 }
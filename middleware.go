@@ -0,0 +1,45 @@
+package fetch
+
+// Handler performs a request and reports its outcome to callback.
+// Handlers must call callback exactly once; they may do it asynchronously.
+type Handler func(r *Request, callback func(*Response, error))
+
+// Middleware wraps a Handler to run code around every request of a client:
+// inject headers, log, measure latency or rewrite responses. A middleware
+// may short-circuit by calling the callback without calling next (e.g. to
+// serve a cached response) or call next again to retry.
+//
+//	func Auth(token string) fetch.Middleware {
+//		return func(next fetch.Handler) fetch.Handler {
+//			return func(r *fetch.Request, callback func(*fetch.Response, error)) {
+//				next(r.Header("Authorization", "Bearer "+token), callback)
+//			}
+//		}
+//	}
+type Middleware func(next Handler) Handler
+
+// Use registers middleware on the default client.
+func Use(mw ...Middleware) {
+	defaultClient.Use(mw...)
+}
+
+// Use registers middleware on the client. Middleware runs in registration
+// order: the first one registered is the outermost.
+func (c *Client) Use(mw ...Middleware) *Client {
+	c.middleware = append(c.middleware, mw...)
+	return c
+}
+
+// chain wraps h with the client cache, request coalescing and middleware.
+func (c *Client) chain(h Handler) Handler {
+	if c.cache != nil {
+		h = c.cache.handler(h)
+	}
+	if c.flights != nil {
+		h = c.flights.handler(h)
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		h = c.middleware[i](h)
+	}
+	return h
+}
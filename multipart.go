@@ -0,0 +1,57 @@
+package fetch
+
+// Multipart is a multipart/form-data body made of text fields and files.
+// The boundary is chosen when the request is sent: the standard library
+// streams the parts with the matching Content-Type header, and WASM builds
+// a native FormData so the browser sets it.
+type Multipart struct {
+	parts []MultipartPart
+}
+
+// MultipartPart is a single part of a Multipart body.
+// Text fields have an empty Filename.
+type MultipartPart struct {
+	Name        string
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// NewMultipart creates an empty multipart/form-data body.
+func NewMultipart() *Multipart {
+	return &Multipart{}
+}
+
+// Field adds a text field.
+func (m *Multipart) Field(name, value string) *Multipart {
+	m.parts = append(m.parts, MultipartPart{Name: name, Data: []byte(value)})
+	return m
+}
+
+// File adds a file part. An empty contentType means application/octet-stream.
+func (m *Multipart) File(name, filename, contentType string, data []byte) *Multipart {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	m.parts = append(m.parts, MultipartPart{Name: name, Filename: filename, ContentType: contentType, Data: data})
+	return m
+}
+
+// Parts returns the parts in the order they were added.
+func (m *Multipart) Parts() []MultipartPart {
+	return m.parts
+}
+
+// Multipart sets a multipart/form-data body, replacing any previous body.
+// Do not set Content-Type yourself: it must carry the boundary.
+func (r *Request) Multipart(m *Multipart) *Request {
+	r.body = nil
+	r.bodyReader = nil
+	r.multipart = m
+	return r
+}
+
+// GetMultipart returns the multipart body of the request, or nil.
+func (r *Request) GetMultipart() *Multipart {
+	return r.multipart
+}
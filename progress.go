@@ -0,0 +1,81 @@
+package fetch
+
+import (
+	"io"
+
+	. "github.com/tinywasm/fmt"
+)
+
+// OnUploadProgress sets fn to be called as the request body is sent, with
+// the bytes sent so far and the body size, or -1 when unknown.
+//
+// In WASM, fetch cannot report upload progress: BodyReader bodies sent as
+// request streams are counted as the browser pulls them, and any other
+// body is sent with XMLHttpRequest instead.
+func (r *Request) OnUploadProgress(fn func(sent, total int64)) *Request {
+	r.uploadProgress = fn
+	return r
+}
+
+// OnDownloadProgress sets fn to be called as the response body arrives,
+// with the bytes received so far and the Content-Length, or -1 when unknown.
+// Browsers report the Content-Length of compressed bodies, so received may
+// exceed total.
+func (r *Request) OnDownloadProgress(fn func(received, total int64)) *Request {
+	r.downloadProgress = fn
+	return r
+}
+
+// progressReader reports the bytes read through it to fn.
+type progressReader struct {
+	io.Reader
+	n     int64
+	total int64
+	fn    func(n, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.Reader.Read(b)
+	if n > 0 {
+		p.n += int64(n)
+		p.fn(p.n, p.total)
+	}
+	return n, err
+}
+
+// uploadBody wraps body to report to the upload progress callback.
+// A closable body stays closable, so the transport can release it.
+func (r *Request) uploadBody(body io.Reader, total int64) io.Reader {
+	if r.uploadProgress == nil {
+		return body
+	}
+	progress := &progressReader{Reader: body, total: total, fn: r.uploadProgress}
+	if closer, ok := body.(io.Closer); ok {
+		return struct {
+			io.Reader
+			io.Closer
+		}{progress, closer}
+	}
+	return progress
+}
+
+// downloadBody wraps body to report to the download progress callback.
+func (r *Request) downloadBody(body io.ReadCloser, total int64) io.ReadCloser {
+	if r.downloadProgress == nil {
+		return body
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{&progressReader{Reader: body, total: total, fn: r.downloadProgress}, body}
+}
+
+// contentLength parses a Content-Length header value, returning -1 when
+// it is missing or invalid.
+func contentLength(value string) int64 {
+	n, err := Convert(value).Int64()
+	if value == "" || err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,97 @@
+package fetch
+
+import (
+	. "github.com/tinywasm/fmt"
+)
+
+// KeyValue is a single key-value pair of a query string or form body.
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// Values is an ordered list of key-value pairs in which keys may repeat,
+// used for query strings (Request.QueryValues) and form bodies (Request.Form).
+// Like []Header it is a slice rather than a map, which keeps insertion
+// order and stays TinyGo friendly.
+type Values []KeyValue
+
+// Add appends a key-value pair and returns the updated list.
+func (v Values) Add(key, value string) Values {
+	return append(v, KeyValue{Key: key, Value: value})
+}
+
+// Encode returns the pairs as "k1=v1&k2=v2" in order, percent-encoding
+// keys and values.
+func (v Values) Encode() string {
+	var buf []byte
+	for i, kv := range v {
+		if i > 0 {
+			buf = append(buf, '&')
+		}
+		buf = appendEscaped(buf, kv.Key, isUnreserved)
+		buf = append(buf, '=')
+		buf = appendEscaped(buf, kv.Value, isUnreserved)
+	}
+	return string(buf)
+}
+
+// Query adds a query parameter to the request URL. Keys and values are
+// percent-encoded and repeated keys are kept in order.
+func (r *Request) Query(key, value string) *Request {
+	r.query = r.query.Add(key, value)
+	return r
+}
+
+// QueryValues adds several query parameters to the request URL, in order.
+func (r *Request) QueryValues(values Values) *Request {
+	r.query = append(r.query, values...)
+	return r
+}
+
+// Form sets the request body to values encoded as
+// application/x-www-form-urlencoded and sets the matching Content-Type.
+//
+//	fetch.Post("/login").Form(fetch.Values{}.Add("user", "ana").Add("pass", "s3cret"))
+func (r *Request) Form(values Values) *Request {
+	return r.Body([]byte(values.Encode())).ContentTypeForm()
+}
+
+// appendQuery adds an encoded query to url, after any query already
+// present and before the fragment.
+func appendQuery(url, query string) string {
+	if query == "" {
+		return url
+	}
+	fragment := ""
+	if i := Index(url, "#"); i >= 0 {
+		url, fragment = url[:i], url[i:]
+	}
+	switch {
+	case Index(url, "?") < 0:
+		url += "?"
+	case !HasSuffix(url, "?") && !HasSuffix(url, "&"):
+		url += "&"
+	}
+	return url + query + fragment
+}
+
+// isUnreserved reports whether c is an RFC 3986 unreserved character.
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// appendEscaped percent-encodes every byte of s not accepted by keep.
+func appendEscaped(buf []byte, s string, keep func(byte) bool) []byte {
+	const hex = "0123456789ABCDEF"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if keep(c) {
+			buf = append(buf, c)
+			continue
+		}
+		buf = append(buf, '%', hex[c>>4], hex[c&15])
+	}
+	return buf
+}
@@ -0,0 +1,250 @@
+package fetch
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/tinywasm/fmt"
+)
+
+// RateLimit configures a client-side token bucket: requests take a token
+// and wait, without blocking, until one is available. Every origin
+// (scheme, host and port) has its own bucket.
+type RateLimit struct {
+	// Rate is the number of requests per second allowed on average.
+	// Zero leaves the rate unlimited, so only learned limits apply.
+	Rate float64
+	// Burst is the number of requests that may be sent at once.
+	// Defaults to 1.
+	Burst int
+	// FromHeaders makes the limiter follow the limits reported by the
+	// server: X-RateLimit-Remaining / X-RateLimit-Reset, their
+	// RateLimit-* equivalents, the combined RateLimit header and
+	// Retry-After on 429 responses. Once no request remains, requests wait
+	// until the reset.
+	//
+	// In WASM, cross-origin servers must list these headers in
+	// Access-Control-Expose-Headers, or the browser hides them.
+	FromHeaders bool
+}
+
+// SetRateLimit sets a rate limit on the default client.
+// See Client.SetRateLimit.
+func SetRateLimit(baseURL string, limit RateLimit) {
+	defaultClient.SetRateLimit(baseURL, limit)
+}
+
+// SetRateLimit limits the requests of the client under baseURL; an empty
+// baseURL applies to every request. When several limits match, the one
+// with the longest base URL is used. Setting a zero RateLimit removes the
+// limit of baseURL.
+//
+// Every attempt of a retried request takes a token. A request whose wait
+// would exceed its timeout fails at once with ErrTimeout, and aborting a
+// waiting request gives its token back.
+func (c *Client) SetRateLimit(baseURL string, limit RateLimit) *Client {
+	prefix := baseURL
+	if baseURL != "" {
+		if u, err := buildFullURL(baseURL, "", ""); err == nil {
+			prefix = u
+		}
+	}
+	for i, l := range c.rateLimits {
+		if l.prefix == prefix {
+			c.rateLimits = append(c.rateLimits[:i:i], c.rateLimits[i+1:]...)
+			break
+		}
+	}
+	if limit.Rate > 0 || limit.FromHeaders {
+		c.rateLimits = append(c.rateLimits, &rateLimiter{
+			prefix:  prefix,
+			limit:   limit,
+			burst:   float64(max(limit.Burst, 1)),
+			buckets: make(map[string]*bucket),
+		})
+	}
+	return c
+}
+
+// rateLimiter returns the rate limiter applying to url, if any.
+func (c *Client) rateLimiter(url string) *rateLimiter {
+	var found *rateLimiter
+	for _, l := range c.rateLimits {
+		if underBase(url, l.prefix) && (found == nil || len(l.prefix) > len(found.prefix)) {
+			found = l
+		}
+	}
+	return found
+}
+
+// underBase reports whether url is base or below it.
+func underBase(url, base string) bool {
+	if !HasPrefix(url, base) {
+		return false
+	}
+	if len(url) == len(base) || base == "" || base[len(base)-1] == '/' {
+		return true
+	}
+	switch url[len(base)] {
+	case '/', '?', '#':
+		return true
+	}
+	return false
+}
+
+// rateLimiter holds the token buckets of a RateLimit.
+type rateLimiter struct {
+	prefix string
+	limit  RateLimit
+	burst  float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket // by origin
+}
+
+// bucket holds the tokens of an origin. Tokens go negative when requests
+// wait for them, and last is in the future while a learned limit pauses
+// the origin.
+type bucket struct {
+	tokens float64
+	last   time.Time // time the tokens were last refilled
+}
+
+// do sends r through next once a token is available.
+func (l *rateLimiter) do(r *Request, next Handler, callback func(*Response, error)) {
+	host := urlOrigin(r.url)
+	send := func(r *Request) {
+		next(r, func(resp *Response, err error) {
+			if resp != nil && l.limit.FromHeaders {
+				l.learn(host, resp)
+			}
+			callback(resp, err)
+		})
+	}
+
+	wait := l.reserve(host, time.Now())
+	if wait <= 0 {
+		send(r)
+		return
+	}
+	ms := int((wait + time.Millisecond - 1) / time.Millisecond)
+	timeout := r.GetTimeout()
+	if timeout > 0 && ms >= timeout {
+		l.cancel(host)
+		callback(nil, newError(KindTimeout, r, r.url, Err("rate limit wait exceeds the timeout")))
+		return
+	}
+	sleep(r.ctx, ms, func(err error) {
+		if err != nil {
+			l.cancel(host)
+			callback(nil, newError(contextErrorKind(err), r, r.url, err))
+			return
+		}
+		if timeout <= 0 {
+			send(r)
+			return
+		}
+		// The transport gets what is left of the timeout.
+		req := *r
+		req.timeout = timeout - ms
+		send(&req)
+	})
+}
+
+// bucket returns the bucket of host refilled up to now.
+func (l *rateLimiter) bucket(host string, now time.Time) *bucket {
+	b, ok := l.buckets[host]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[host] = b
+	}
+	if now.After(b.last) {
+		if l.limit.Rate > 0 {
+			b.tokens = min(b.tokens+l.limit.Rate*now.Sub(b.last).Seconds(), l.burst)
+		} else {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+	return b
+}
+
+// reserve takes a token for a request to host and returns how long the
+// request must wait for it.
+func (l *rateLimiter) reserve(host string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.bucket(host, now)
+	wait := b.last.Sub(now)
+	b.tokens--
+	if b.tokens < 0 {
+		if l.limit.Rate > 0 {
+			wait += time.Duration(-b.tokens / l.limit.Rate * float64(time.Second))
+		} else {
+			b.tokens = 0
+		}
+	}
+	return wait
+}
+
+// cancel gives back the token of a request that was not sent.
+func (l *rateLimiter) cancel(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.bucket(host, time.Now())
+	b.tokens = min(b.tokens+1, l.burst)
+}
+
+// learn applies the limits reported by resp to the bucket of host.
+func (l *rateLimiter) learn(host string, resp *Response) {
+	now := time.Now()
+	var pause time.Duration
+	remaining, hasRemaining := rateLimitValue(resp, "remaining")
+	if resp.Status == 429 {
+		if ms, ok := parseRetryAfter(resp.GetHeader("Retry-After")); ok {
+			pause = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if reset, ok := rateLimitValue(resp, "reset"); ok && hasRemaining && remaining <= 0 {
+		// Reset is a delay in seconds, or a Unix time in some APIs.
+		if reset > 1e9 {
+			reset -= now.Unix()
+		}
+		pause = max(pause, time.Duration(reset)*time.Second)
+	}
+	if pause <= 0 && !hasRemaining {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.bucket(host, now)
+	if pause > 0 {
+		b.tokens = min(b.tokens, 0)
+		if until := now.Add(pause); until.After(b.last) {
+			b.last = until
+		}
+	} else if l.limit.Rate > 0 {
+		b.tokens = min(b.tokens, float64(remaining))
+	}
+}
+
+// rateLimitValue returns the named value ("remaining" or "reset") of the
+// rate limit headers of resp.
+func rateLimitValue(resp *Response, name string) (int64, bool) {
+	value := resp.GetHeader("X-RateLimit-" + name)
+	if value == "" {
+		value = resp.GetHeader("RateLimit-" + name)
+	}
+	if value == "" {
+		value, _ = directive(resp.GetHeader("RateLimit"), name)
+	}
+	if value == "" {
+		return 0, false
+	}
+	n, err := Convert(value).Int64()
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
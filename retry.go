@@ -0,0 +1,190 @@
+package fetch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "github.com/tinywasm/fmt"
+)
+
+// RetryPolicy configures automatic retries of failed requests.
+// Network errors, timeouts and the listed statuses are retried with
+// exponential backoff and jitter, honouring the Retry-After header. In
+// WASM, cross-origin servers must list Retry-After in
+// Access-Control-Expose-Headers for the browser to reveal it.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry in milliseconds.
+	// It doubles on every retry. Defaults to 200.
+	BaseDelay int
+	// MaxDelay caps backoff and Retry-After waits in milliseconds.
+	// Defaults to 30000.
+	MaxDelay int
+	// Statuses are the response statuses to retry.
+	// Defaults to 408, 429, 502, 503 and 504.
+	Statuses []int
+	// AllowNonIdempotent enables retries of POST and PATCH requests.
+	AllowNonIdempotent bool
+}
+
+// defaultRetryStatuses are retried when RetryPolicy.Statuses is empty.
+var defaultRetryStatuses = []int{408, 429, 502, 503, 504}
+
+// Retry sets the retry policy of the request, overriding the client one.
+func (r *Request) Retry(policy RetryPolicy) *Request {
+	r.retry = policy
+	return r
+}
+
+// SetRetry sets the default retry policy of the client.
+func (c *Client) SetRetry(policy RetryPolicy) *Client {
+	c.retry = policy
+	return c
+}
+
+// retryPolicy returns the effective policy of the request with defaults applied.
+func (r *Request) retryPolicy() RetryPolicy {
+	p := r.retry
+	if p.MaxAttempts == 0 {
+		p = r.client.retry
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 200
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 30000
+	}
+	if len(p.Statuses) == 0 {
+		p.Statuses = defaultRetryStatuses
+	}
+	return p
+}
+
+// shouldRetry reports whether the outcome of an attempt is worth retrying.
+func (p RetryPolicy) shouldRetry(resp *Response, err error) bool {
+	if err != nil {
+		e, ok := err.(*Error)
+		if !ok {
+			return false
+		}
+		// Browsers report network failures of cross-origin requests as
+		// likely CORS blocks, so those are retried too.
+		switch e.Kind {
+		case KindNetwork, KindTimeout, KindCORSLikely, KindBodyRead:
+			return true
+		}
+		return false
+	}
+	for _, status := range p.Statuses {
+		if resp.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the wait in milliseconds before the given retry (1-based).
+func (p RetryPolicy) delay(retry int, resp *Response) int {
+	if resp != nil {
+		if ms, ok := parseRetryAfter(resp.GetHeader("Retry-After")); ok {
+			return min(ms, p.MaxDelay)
+		}
+	}
+	d := p.BaseDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	// Equal jitter: keep half of the delay, randomize the other half.
+	return d/2 + int(random()*float64(d/2+1))
+}
+
+// parseRetryAfter converts a Retry-After value (seconds or HTTP date) to milliseconds.
+func parseRetryAfter(value string) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := Convert(value).Int(); err == nil {
+		return max(secs, 0) * 1000, true
+	}
+	t, err := time.Parse(time.RFC1123, value)
+	if err != nil {
+		return 0, false
+	}
+	return max(int(time.Until(t).Milliseconds()), 0), true
+}
+
+// isIdempotent reports whether requests with method can be safely repeated.
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE", "TRACE":
+		return true
+	}
+	return false
+}
+
+// sendWithRetry performs the request, retrying it according to its policy.
+// Streamed bodies cannot be replayed, so those requests are sent once.
+func sendWithRetry(r *Request, callback func(*Response, error)) {
+	policy := r.retryPolicy()
+	if policy.MaxAttempts < 2 || r.bodyReader != nil || (!policy.AllowNonIdempotent && !isIdempotent(r.method)) {
+		doRequest(r, callback)
+		return
+	}
+
+	attempt := 1
+	var try func()
+	try = func() {
+		doRequest(r, func(resp *Response, err error) {
+			if attempt >= policy.MaxAttempts || !policy.shouldRetry(resp, err) {
+				callback(resp, err)
+				return
+			}
+			wait := policy.delay(attempt, resp)
+			attempt++
+			if resp != nil && resp.stream != nil {
+				resp.stream.Close()
+			}
+			sleep(r.ctx, wait, func(ctxErr error) {
+				if ctxErr != nil {
+					callback(nil, newError(contextErrorKind(ctxErr), r, r.url, ctxErr))
+					return
+				}
+				try()
+			})
+		})
+	}
+	try()
+}
+
+// sleep calls fn with nil after ms milliseconds, or with the context error
+// as soon as ctx is done. fn is called once and nothing blocks meanwhile.
+func sleep(ctx context.Context, ms int, fn func(error)) {
+	var mu sync.Mutex
+	var fired bool
+	fire := func(err error) {
+		mu.Lock()
+		done := fired
+		fired = true
+		mu.Unlock()
+		if !done {
+			fn(err)
+		}
+	}
+	stopTimer := afterFunc(ms, func() { fire(nil) })
+	context.AfterFunc(ctx, func() {
+		stopTimer()
+		fire(ctx.Err())
+	})
+}
+
+// contextErrorKind maps a context error to its ErrorKind.
+func contextErrorKind(err error) ErrorKind {
+	if err == context.DeadlineExceeded {
+		return KindTimeout
+	}
+	return KindAborted
+}
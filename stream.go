@@ -0,0 +1,88 @@
+package fetch
+
+import (
+	"bytes"
+	"io"
+)
+
+// BodyReader sets a request body streamed from reader instead of held in
+// memory, replacing any previous body. size is the body length in bytes,
+// or -1 when unknown, in which case the stdlib backend uses chunked
+// transfer encoding.
+//
+// In WASM the body is streamed with a ReadableStream when the browser
+// supports request streams and the URL is https (streaming needs HTTP/2);
+// otherwise it is read into memory first.
+//
+// The reader can only be consumed once: requests with a BodyReader are
+// never retried and must not be sent again.
+func (r *Request) BodyReader(reader io.Reader, size int64) *Request {
+	r.body = nil
+	r.multipart = nil
+	r.bodyReader = reader
+	r.bodySize = size
+	return r
+}
+
+// GetBodyReader returns the streamed body of the request and its size,
+// or nil when the request has none.
+func (r *Request) GetBodyReader() (io.Reader, int64) {
+	return r.bodyReader, r.bodySize
+}
+
+// Stream delivers the response as soon as its status and headers arrive,
+// with the body readable incrementally through Response.BodyReader instead
+// of being read into memory. The caller must close the reader. Timeout,
+// Context and Call.Abort keep applying while the body is read.
+func (r *Request) Stream() *Request {
+	r.stream = true
+	return r
+}
+
+// IsStream reports whether the request was sent with Stream, so a
+// Transport should deliver the body with Response.SetBodyReader.
+func (r *Request) IsStream() bool {
+	return r.stream
+}
+
+// SetBodyReader replaces the response body with a stream, e.g. from a
+// Transport serving a Stream request.
+func (r *Response) SetBodyReader(body io.ReadCloser) *Response {
+	r.body = nil
+	r.stream = body
+	return r
+}
+
+// BodyReader returns the response body. For requests sent with Stream it
+// is the body as it arrives, and closing it releases the request; the
+// caller must close it. Otherwise it reads the body held in memory.
+func (r *Response) BodyReader() io.ReadCloser {
+	if r.stream != nil {
+		return r.stream
+	}
+	return io.NopCloser(bytes.NewReader(r.body))
+}
+
+// readStream buffers the rest of a streamed body and closes the stream.
+func (r *Response) readStream() error {
+	if r.stream == nil {
+		return nil
+	}
+	var err error
+	r.body, err = io.ReadAll(r.stream)
+	r.stream.Close()
+	r.stream = nil
+	return err
+}
+
+// releasingBody calls release after the stream it wraps is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
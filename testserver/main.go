@@ -8,8 +8,11 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,9 +20,9 @@ import (
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Custom")
-		w.Header().Set("Access-Control-Expose-Headers", "X-Test-Simple, X-Reflected-X-Custom")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS, REPORT")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Custom, Cache-Control, If-None-Match, If-Modified-Since")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Test-Simple, X-Reflected-X-Custom, X-Method, X-Calls, ETag, Vary, Retry-After, X-RateLimit-Remaining, X-RateLimit-Reset, RateLimit-Remaining, RateLimit-Reset, RateLimit")
 
 		// Handle preflight requests
 		if r.Method == http.MethodOptions {
@@ -66,6 +69,126 @@ func main() {
 		w.Write(body)
 	})
 
+	// Handler for multipart uploads, echoes fields and files as "name=value;" pairs
+	mux.HandleFunc("/multipart", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var out strings.Builder
+		for _, name := range []string{"title", "tag"} {
+			for _, v := range r.MultipartForm.Value[name] {
+				out.WriteString(name + "=" + v + ";")
+			}
+		}
+		for _, fh := range r.MultipartForm.File["file"] {
+			f, _ := fh.Open()
+			content, _ := io.ReadAll(f)
+			f.Close()
+			out.WriteString("file=" + fh.Filename + ":" + fh.Header.Get("Content-Type") + ":" + string(content) + ";")
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(out.String()))
+	})
+
+	// Handler for URL-encoded forms, echoes "content-type|raw body|values of b"
+	mux.HandleFunc("/form", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(r.Header.Get("Content-Type") + "|" + string(body) + "|" + strings.Join(values["b"], ",")))
+	})
+
+	// Handler streaming "lines" NDJSON lines, flushing each one and waiting
+	// "delay" milliseconds between them
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		lines, _ := strconv.Atoi(r.URL.Query().Get("lines"))
+		delay, _ := strconv.Atoi(r.URL.Query().Get("delay"))
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		for i := 1; i <= lines; i++ {
+			if i > 1 {
+				select {
+				case <-time.After(time.Duration(delay) * time.Millisecond):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			w.Write([]byte(`{"n":` + strconv.Itoa(i) + "}\n"))
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	// Handler for HTTP cache tests, counting the calls with the same "id".
+	// It sends the "cache_control" and "vary" query values as headers and
+	// answers 304 when If-None-Match matches the "etag" value.
+	var cachedMu sync.Mutex
+	cachedCalls := map[string]int{}
+	mux.HandleFunc("/cached", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		cachedMu.Lock()
+		cachedCalls[q.Get("id")]++
+		calls := strconv.Itoa(cachedCalls[q.Get("id")])
+		cachedMu.Unlock()
+
+		w.Header().Set("X-Calls", calls)
+		if cc := q.Get("cache_control"); cc != "" {
+			w.Header().Set("Cache-Control", cc)
+		}
+		if etag := q.Get("etag"); etag != "" {
+			w.Header().Set("ETag", `"`+etag+`"`)
+			if r.Header.Get("If-None-Match") == `"`+etag+`"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		body := "calls=" + calls
+		if vary := q.Get("vary"); vary != "" {
+			w.Header().Set("Vary", vary)
+			body += " " + vary + "=" + r.Header.Get(vary)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(body))
+	})
+
+	// Handler counting the calls with the same "id", answering the count
+	// after waiting "delay" milliseconds
+	var counterMu sync.Mutex
+	counterCalls := map[string]int{}
+	mux.HandleFunc("/counter", func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		delay, _ := strconv.Atoi(r.URL.Query().Get("delay"))
+		counterMu.Lock()
+		counterCalls[id]++
+		calls := counterCalls[id]
+		counterMu.Unlock()
+		select {
+		case <-time.After(time.Duration(delay) * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(strconv.Itoa(calls)))
+	})
+
+	// Handler reporting a rate limit, sending the "remaining" and "reset"
+	// query values as X-RateLimit-Remaining and X-RateLimit-Reset headers,
+	// or with the "prefix" query value in place of "X-RateLimit-"
+	mux.HandleFunc("/ratelimit", func(w http.ResponseWriter, r *http.Request) {
+		prefix := r.URL.Query().Get("prefix")
+		if prefix == "" {
+			prefix = "X-RateLimit-"
+		}
+		w.Header().Set(prefix+"Remaining", r.URL.Query().Get("remaining"))
+		w.Header().Set(prefix+"Reset", r.URL.Query().Get("reset"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
 	// Handler for PUT requests
 	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPut {
@@ -86,6 +209,30 @@ func main() {
 		w.Write([]byte("delete success"))
 	})
 
+	// Handler that echoes the escaped request path of any URL under /path/
+	mux.HandleFunc("/path/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(r.URL.EscapedPath()))
+	})
+
+	// Handler that echoes the raw query string
+	mux.HandleFunc("/query", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(r.URL.RawQuery))
+	})
+
+	// Handler that echoes the request method in the body and the X-Method header
+	mux.HandleFunc("/method", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Method", r.Method)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(r.Method))
+	})
+
+	// Handler that answers 204 No Content
+	mux.HandleFunc("/no_content", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
 	// Handler that reflects headers
 	mux.HandleFunc("/headers", func(w http.ResponseWriter, r *http.Request) {
 		for k, v := range r.Header {
@@ -108,6 +255,28 @@ func main() {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 	})
 
+	// Handler that fails with 503 for the first "fail" calls with the same "id",
+	// sending the optional "retry_after" value as Retry-After header
+	var flakyMu sync.Mutex
+	flakyCalls := map[string]int{}
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		fail, _ := strconv.Atoi(r.URL.Query().Get("fail"))
+		flakyMu.Lock()
+		flakyCalls[id]++
+		calls := flakyCalls[id]
+		flakyMu.Unlock()
+		if calls <= fail {
+			if after := r.URL.Query().Get("retry_after"); after != "" {
+				w.Header().Set("Retry-After", after)
+			}
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(strconv.Itoa(calls)))
+	})
+
 	// Shutdown handler
 	mux.HandleFunc("/shutdown", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -0,0 +1,52 @@
+package fetch
+
+// Transport performs a single HTTP exchange for a request prepared by Send
+// and reports its outcome to callback exactly once, possibly asynchronously.
+// Implementations read the request with GetMethod, GetURL, GetHeaders,
+// GetBody, GetTimeout and GetContext, and should abort when the context is done.
+//
+// The platform implementation is DefaultTransport: HTTPTransport on the
+// standard library and FetchTransport in WASM.
+type Transport interface {
+	RoundTrip(r *Request, callback func(*Response, error))
+}
+
+// RoundTrip calls h, so any Handler can be used as a Transport.
+func (h Handler) RoundTrip(r *Request, callback func(*Response, error)) {
+	h(r, callback)
+}
+
+// SetTransport sets the transport of the default client.
+func SetTransport(t Transport) {
+	defaultClient.SetTransport(t)
+}
+
+// SetTransport sets the transport used by the client.
+// nil restores DefaultTransport.
+func (c *Client) SetTransport(t Transport) *Client {
+	c.transport = t
+	return c
+}
+
+// doRequest sends r through the client rate limit, in-flight limits and
+// transport.
+func doRequest(r *Request, callback func(*Response, error)) {
+	if l := r.client.rateLimiter(r.url); l != nil {
+		l.do(r, roundTrip, callback)
+		return
+	}
+	roundTrip(r, callback)
+}
+
+// roundTrip sends r through the client in-flight limits and transport.
+func roundTrip(r *Request, callback func(*Response, error)) {
+	t := r.client.transport
+	if t == nil {
+		t = DefaultTransport
+	}
+	if l := r.client.limiter; l != nil {
+		l.do(r, t, callback)
+		return
+	}
+	t.RoundTrip(r, callback)
+}
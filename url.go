@@ -4,16 +4,14 @@ import (
 	. "github.com/tinywasm/fmt"
 )
 
-var defaultBaseURL string
-
-// SetBaseURL sets the global base URL for all requests.
+// SetBaseURL sets the base URL of the default client.
 func SetBaseURL(url string) {
-	defaultBaseURL = url
+	defaultClient.SetBaseURL(url)
 }
 
-// GetBaseURL returns the current global base URL.
+// GetBaseURL returns the base URL of the default client.
 func GetBaseURL() string {
-	return defaultBaseURL
+	return defaultClient.GetBaseURL()
 }
 
 // buildURL constructs the full request URL using the new resolution logic.
@@ -27,5 +25,130 @@ func buildURL(r *Request) (string, error) {
 		return "", Err("endpoint cannot be empty")
 	}
 
-	return buildFullURL(endpoint, r.baseURL)
+	provider, _ := r.endpoint.(PathParamProvider)
+	endpoint, err = expandPathParams(endpoint, r.params, provider)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := buildFullURL(endpoint, r.baseURL, r.client.baseURL)
+	if err != nil {
+		return "", err
+	}
+	return appendQuery(url, r.query.Encode()), nil
+}
+
+// urlOrigin returns the "scheme://host[:port]" part of an absolute URL.
+func urlOrigin(url string) string {
+	u := parseURI(url)
+	if u.scheme == "" || !u.hasAuthority {
+		return ""
+	}
+	return u.scheme + "://" + u.authority
+}
+
+// uri holds the components of a URI reference (RFC 3986, section 3).
+// The has* flags tell an empty component from a missing one.
+type uri struct {
+	scheme       string
+	authority    string
+	path         string
+	query        string
+	fragment     string
+	hasAuthority bool
+	hasQuery     bool
+	hasFragment  bool
+}
+
+// parseURI splits s into its components (RFC 3986, appendix B).
+// The scheme is lowercased; it is empty for relative references.
+func parseURI(s string) uri {
+	var u uri
+	if i := Index(s, "#"); i >= 0 {
+		s, u.fragment, u.hasFragment = s[:i], s[i+1:], true
+	}
+	if i := Index(s, "?"); i >= 0 {
+		s, u.query, u.hasQuery = s[:i], s[i+1:], true
+	}
+	if n := schemeLength(s); n > 0 {
+		u.scheme, s = Convert(s[:n]).ToLower().String(), s[n+1:]
+	}
+	if HasPrefix(s, "//") {
+		s = s[2:]
+		end := Index(s, "/")
+		if end < 0 {
+			end = len(s)
+		}
+		u.authority, s, u.hasAuthority = s[:end], s[end:], true
+	}
+	u.path = s
+	return u
+}
+
+// schemeLength returns the length of the scheme at the start of s, or 0.
+// scheme = ALPHA *( ALPHA / DIGIT / "+" / "-" / "." ) followed by ":".
+func schemeLength(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' || c == '+' || c == '-' || c == '.':
+			if i == 0 {
+				return 0
+			}
+		case c == ':':
+			return i
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// String recomposes the URI (RFC 3986, section 5.3).
+func (u uri) String() string {
+	s := ""
+	if u.scheme != "" {
+		s += u.scheme + ":"
+	}
+	if u.hasAuthority {
+		s += "//" + u.authority
+	}
+	s += u.path
+	if u.hasQuery {
+		s += "?" + u.query
+	}
+	if u.hasFragment {
+		s += "#" + u.fragment
+	}
+	return s
+}
+
+// removeDotSegments interprets "." and ".." segments of a path
+// (RFC 3986, section 5.2.4). Other segments, including empty ones, are kept.
+func removeDotSegments(path string) string {
+	if Index(path, ".") < 0 {
+		return path
+	}
+	var out []string
+	segments := Convert(path).Split("/")
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch seg {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 || (len(out) == 1 && out[0] != "") {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+	return Convert(out).Join("/").String()
 }